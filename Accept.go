@@ -0,0 +1,45 @@
+package gotten
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+const (
+	MediaRangeAll = "*/*"
+)
+
+// Accept generates the value of Accept header by content types of AcceptCheckers;
+// unmarshalers checked earlier get higher q-values, from 1 down to 0.1
+func (unmarshalers ConditionalUnmarshalers) Accept() string {
+	ranges := make([]string, 0)
+	added := make(map[string]bool)
+	quality := 10 // tenths
+	for _, conditional := range unmarshalers {
+		checker, ok := conditional.checker.(AcceptChecker)
+		if !ok {
+			continue
+		}
+
+		anyAdded := false
+		for _, contentType := range checker.Accept() {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || added[mediaType] {
+				continue
+			}
+			added[mediaType] = true
+			anyAdded = true
+			if quality == 10 {
+				ranges = append(ranges, mediaType)
+			} else {
+				ranges = append(ranges, fmt.Sprintf("%s;q=0.%d", mediaType, quality))
+			}
+		}
+
+		if anyAdded && quality > 1 {
+			quality--
+		}
+	}
+	return strings.Join(ranges, ", ")
+}
@@ -0,0 +1,366 @@
+package gotten
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// virtual nodes of an endpoint of weight 1 on the ring of ConsistentHash
+	HashReplicas = 100
+)
+
+type (
+	// Endpoint is one of base urls added by Builder.AddEndpoint
+	Endpoint struct {
+		URL    *url.URL
+		Weight int
+
+		inFlight     int64 // atomic
+		mutex        sync.Mutex
+		failures     int // consecutive
+		ejectedUntil time.Time
+		unhealthy    bool
+		current      int // of smooth weighted round-robin
+	}
+
+	// Balancer picks an endpoint for each request;
+	// endpoints are available ones, or all of them if none is available
+	Balancer interface {
+		Pick(endpoints []*Endpoint, req *http.Request) *Endpoint
+	}
+
+	BalancerFunc func(endpoints []*Endpoint, req *http.Request) *Endpoint
+
+	roundRobin struct {
+		next uint64 // atomic
+	}
+
+	weighted struct {
+		mutex sync.Mutex
+	}
+
+	// balancer bound to all endpoints of a pool when the pool is created, like ConsistentHash
+	poolBalancer interface {
+		bind(endpoints []*Endpoint) Balancer
+	}
+
+	consistentHash struct {
+		key   func(req *http.Request) string
+		ring  []uint32
+		nodes map[uint32]*Endpoint
+	}
+
+	// added by Builder.AddEndpoint
+	endpointSpec struct {
+		url    string
+		weight int
+	}
+
+	// endpoints shared by all functions of a Creator
+	endpointPool struct {
+		primary   *url.URL // the url requests are built with
+		endpoints []*Endpoint
+		balancer  Balancer
+
+		// eject endpoint for ejectTime after maxFailures consecutive failures; never eject if maxFailures is 0
+		maxFailures int
+		ejectTime   time.Duration
+
+		stopHealthCheck chan struct{}
+		stopOnce        sync.Once
+	}
+
+	// decrease in-flight count when body is closed or read to EOF
+	inFlightBody struct {
+		io.ReadCloser
+		once     sync.Once
+		endpoint *Endpoint
+	}
+)
+
+func (fn BalancerFunc) Pick(endpoints []*Endpoint, req *http.Request) *Endpoint {
+	return fn(endpoints, req)
+}
+
+// InFlight returns number of requests whose responses are not closed
+func (endpoint *Endpoint) InFlight() int64 {
+	return atomic.LoadInt64(&endpoint.inFlight)
+}
+
+// Available returns false if endpoint is ejected or unhealthy
+func (endpoint *Endpoint) Available() bool {
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+	return !endpoint.unhealthy && !time.Now().Before(endpoint.ejectedUntil)
+}
+
+func (endpoint *Endpoint) setHealthy(healthy bool) {
+	endpoint.mutex.Lock()
+	endpoint.unhealthy = !healthy
+	endpoint.mutex.Unlock()
+}
+
+// RoundRobin picks endpoints in turn, the default Balancer
+func RoundRobin() Balancer {
+	return new(roundRobin)
+}
+
+func (balancer *roundRobin) Pick(endpoints []*Endpoint, _ *http.Request) *Endpoint {
+	next := atomic.AddUint64(&balancer.next, 1) - 1
+	return endpoints[next%uint64(len(endpoints))]
+}
+
+// LeastInFlight picks the endpoint with the fewest in-flight requests, the former one on ties
+func LeastInFlight() Balancer {
+	return BalancerFunc(func(endpoints []*Endpoint, _ *http.Request) (picked *Endpoint) {
+		for _, endpoint := range endpoints {
+			if picked == nil || endpoint.InFlight() < picked.InFlight() {
+				picked = endpoint
+			}
+		}
+		return
+	})
+}
+
+// Weighted picks endpoints by smooth weighted round-robin
+func Weighted() Balancer {
+	return new(weighted)
+}
+
+func (balancer *weighted) Pick(endpoints []*Endpoint, _ *http.Request) (picked *Endpoint) {
+	balancer.mutex.Lock()
+	defer balancer.mutex.Unlock()
+	total := 0
+	for _, endpoint := range endpoints {
+		endpoint.current += endpoint.Weight
+		total += endpoint.Weight
+		if picked == nil || endpoint.current > picked.current {
+			picked = endpoint
+		}
+	}
+	picked.current -= total
+	return
+}
+
+// ConsistentHash picks endpoint on a ring hashed by key of request, like HashQuery or HashHeader;
+// a key is moved to the next endpoint on the ring if its endpoint is unavailable
+func ConsistentHash(key func(req *http.Request) string) Balancer {
+	return &consistentHash{key: key}
+}
+
+// value of query param of request
+func HashQuery(key string) func(req *http.Request) string {
+	return func(req *http.Request) string {
+		return req.URL.Query().Get(key)
+	}
+}
+
+// value of header of request
+func HashHeader(key string) func(req *http.Request) string {
+	return func(req *http.Request) string {
+		return req.Header.Get(key)
+	}
+}
+
+// the ring is built by all endpoints of the pool, so a balancer can be shared by pools
+func (balancer *consistentHash) bind(endpoints []*Endpoint) Balancer {
+	bound := &consistentHash{key: balancer.key, nodes: make(map[uint32]*Endpoint)}
+	for _, endpoint := range endpoints {
+		for i := 0; i < HashReplicas*endpoint.Weight; i++ {
+			hash := crc32.ChecksumIEEE([]byte(endpoint.URL.String() + "#" + strconv.Itoa(i)))
+			bound.nodes[hash] = endpoint
+			bound.ring = append(bound.ring, hash)
+		}
+	}
+	sort.Slice(bound.ring, func(i, j int) bool { return bound.ring[i] < bound.ring[j] })
+	return bound
+}
+
+// walk the ring clockwise from hash of key, past endpoints which are not available now
+func (balancer *consistentHash) Pick(endpoints []*Endpoint, req *http.Request) *Endpoint {
+	candidates := make(map[*Endpoint]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		candidates[endpoint] = true
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(balancer.key(req)))
+	start := sort.Search(len(balancer.ring), func(i int) bool { return balancer.ring[i] >= hash })
+	for i := 0; i < len(balancer.ring); i++ {
+		if endpoint := balancer.nodes[balancer.ring[(start+i)%len(balancer.ring)]]; candidates[endpoint] {
+			return endpoint
+		}
+	}
+	return endpoints[0]
+}
+
+// primary is the first endpoint
+func newEndpointPool(specs []endpointSpec, balancer Balancer, maxFailures int, ejectTime time.Duration) (pool *endpointPool, err error) {
+	pool = &endpointPool{
+		balancer:    balancer,
+		maxFailures: maxFailures,
+		ejectTime:   ejectTime,
+	}
+	for _, spec := range specs {
+		var endpointUrl *url.URL
+		if endpointUrl, err = url.Parse(spec.url); err != nil {
+			return
+		}
+		pool.endpoints = append(pool.endpoints, &Endpoint{URL: endpointUrl, Weight: spec.weight})
+	}
+	pool.primary = pool.endpoints[0].URL
+	if bindable, ok := balancer.(poolBalancer); ok {
+		pool.balancer = bindable.bind(pool.endpoints)
+	}
+	return
+}
+
+// available endpoints, or all of them if none is available
+func (pool *endpointPool) available() (endpoints []*Endpoint) {
+	for _, endpoint := range pool.endpoints {
+		if endpoint.Available() {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	if len(endpoints) == 0 {
+		endpoints = pool.endpoints
+	}
+	return
+}
+
+// failure is an error or 5xx status; error of canceled or timed out request is ignored,
+// like the hedged request which loses or the caller which gives up,
+// so are CircuitOpenError and LimitError, the request is never sent to endpoint
+func (pool *endpointPool) observe(endpoint *Endpoint, req *http.Request, resp *http.Response, err error) {
+	var openErr *CircuitOpenError
+	var limitErr *LimitError
+	if err != nil && (req.Context().Err() != nil || errors.As(err, &openErr) || errors.As(err, &limitErr)) {
+		return
+	}
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		endpoint.failures++
+		if pool.maxFailures > 0 && endpoint.failures >= pool.maxFailures {
+			endpoint.ejectedUntil = time.Now().Add(pool.ejectTime)
+			endpoint.failures = 0
+		}
+	} else {
+		endpoint.failures = 0
+	}
+}
+
+// url of request built with primary is moved to endpoint
+func (pool *endpointPool) rewrite(req *http.Request, endpoint *Endpoint) *http.Request {
+	if endpoint.URL == pool.primary {
+		return req
+	}
+	target := *req.URL
+	target.Scheme = endpoint.URL.Scheme
+	target.Host = endpoint.URL.Host
+	relative := strings.TrimPrefix(req.URL.Path, strings.TrimRight(pool.primary.Path, "/"))
+	target.Path = strings.TrimRight(endpoint.URL.Path, "/") + "/" + strings.TrimLeft(relative, "/")
+	req = req.Clone(req.Context())
+	req.URL = &target
+	req.Host = ZeroStr
+	return req
+}
+
+func (pool *endpointPool) withBalancer(next doFunc) doFunc {
+	return func(req *http.Request) (resp *http.Response, err error) {
+		endpoint := pool.balancer.Pick(pool.available(), req)
+		atomic.AddInt64(&endpoint.inFlight, 1)
+		resp, err = next(pool.rewrite(req, endpoint))
+		pool.observe(endpoint, req, resp, err)
+		if err != nil {
+			atomic.AddInt64(&endpoint.inFlight, -1)
+			return
+		}
+		resp.Body = &inFlightBody{ReadCloser: resp.Body, endpoint: endpoint}
+		return
+	}
+}
+
+// check every endpoint concurrently by GET path every interval, until stopped;
+// endpoint is unhealthy if it responds an error or a status other than 2xx, or does not respond in interval
+func (pool *endpointPool) runHealthCheck(client Client, path string, interval time.Duration) {
+	pool.stopHealthCheck = make(chan struct{})
+	probe := func(endpoint *Endpoint) {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		defer cancel()
+		target := *endpoint.URL
+		target.Path = strings.TrimRight(target.Path, "/") + "/" + strings.TrimLeft(path, "/")
+		healthy := false
+		if req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil); err == nil {
+			var resp *http.Response
+			if resp, err = client.Do(req); err == nil {
+				healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+				resp.Body.Close()
+			}
+		}
+		endpoint.setHealthy(healthy)
+	}
+
+	// a round of probes ends before the next one starts
+	check := func() {
+		var wg sync.WaitGroup
+		for _, endpoint := range pool.endpoints {
+			wg.Add(1)
+			go func(endpoint *Endpoint) {
+				defer wg.Done()
+				probe(endpoint)
+			}(endpoint)
+		}
+		wg.Wait()
+	}
+
+	go func() {
+		check()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				check()
+			case <-pool.stopHealthCheck:
+				return
+			}
+		}
+	}()
+}
+
+func (pool *endpointPool) stop() {
+	if pool.stopHealthCheck != nil {
+		pool.stopOnce.Do(func() {
+			close(pool.stopHealthCheck)
+		})
+	}
+}
+
+func (body *inFlightBody) done() {
+	body.once.Do(func() {
+		atomic.AddInt64(&body.endpoint.inFlight, -1)
+	})
+}
+
+func (body *inFlightBody) Read(p []byte) (n int, err error) {
+	if n, err = body.ReadCloser.Read(p); err == io.EOF {
+		body.done()
+	}
+	return
+}
+
+func (body *inFlightBody) Close() error {
+	body.done()
+	return body.ReadCloser.Close()
+}
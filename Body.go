@@ -0,0 +1,334 @@
+package gotten
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Hexilee/gotten/headers"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	UnknownLength = -1
+)
+
+type (
+	// body of request; length is UnknownLength if it cannot be computed,
+	// getBody is nil if the body cannot be rewound
+	requestBody struct {
+		reader  io.Reader
+		length  int64
+		getBody func() (io.ReadCloser, error)
+	}
+
+	// multipartPart is opened when it is written
+	multipartPart struct {
+		header     textproto.MIMEHeader
+		size       int64
+		rewindable bool
+		open       func() (io.Reader, error)
+		closer     io.Closer // reader of a part which cannot be rewound, closed even if it is never written
+	}
+
+	multipartParts struct {
+		boundary string
+		parts    []*multipartPart
+	}
+
+	// pipeBody starts writing parts on the first Read, or abandons them if it is closed before
+	pipeBody struct {
+		once   sync.Once
+		parts  *multipartParts
+		reader *io.PipeReader
+		writer *io.PipeWriter
+	}
+
+	counter int64
+)
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// set ContentLength and GetBody, if http.NewRequest cannot recognize the body
+func (body *requestBody) setTo(req *http.Request) {
+	if req.ContentLength == 0 && body.length > 0 {
+		req.ContentLength = body.length
+	}
+
+	if req.GetBody == nil && body.getBody != nil {
+		req.GetBody = body.getBody
+	}
+}
+
+// body of TypeJSON, TypeXML or other BodyMarshalers, can be rewound if it is *bytes.Buffer, *bytes.Reader or *strings.Reader
+func newReaderBody(reader io.Reader) *requestBody {
+	reader = unwrapReader(reader)
+	return &requestBody{reader: reader, length: readerLen(reader)}
+}
+
+// multipart body is streamed through io.Pipe, never buffered
+func newMultipartBody(parts *multipartParts) (body *requestBody) {
+	rewindable := true
+	for _, part := range parts.parts {
+		rewindable = rewindable && part.rewindable
+	}
+
+	body = &requestBody{
+		reader: parts.pipe(),
+		length: parts.length(),
+	}
+
+	if rewindable {
+		body.getBody = func() (io.ReadCloser, error) {
+			return parts.pipe(), nil
+		}
+	}
+	return
+}
+
+func (parts *multipartParts) newWriter(writer io.Writer) (multipartWriter *multipart.Writer) {
+	multipartWriter = multipart.NewWriter(writer)
+	multipartWriter.SetBoundary(parts.boundary)
+	return
+}
+
+func (parts *multipartParts) contentType() string {
+	return parts.newWriter(ioutil.Discard).FormDataContentType()
+}
+
+func (parts *multipartParts) addValue(key, value string) {
+	header := make(textproto.MIMEHeader)
+	header.Set(headers.HeaderContentDisposition, fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(key)))
+	parts.parts = append(parts.parts, &multipartPart{
+		header:     header,
+		size:       int64(len(value)),
+		rewindable: true,
+		open: func() (io.Reader, error) {
+			return strings.NewReader(value), nil
+		},
+	})
+}
+
+// reader implementing io.Closer is closed after written, so it cannot be rewound;
+// *bytes.Buffer or reader implementing io.ReaderAt and io.Seeker can be rewound
+func (parts *multipartParts) addReader(key string, reader io.Reader, partHeader http.Header) error {
+	header := make(textproto.MIMEHeader)
+	for key, values := range partHeader {
+		header[key] = values
+	}
+	header.Set(headers.HeaderContentDisposition, fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(key)))
+	return parts.addReaderPart(header, reader)
+}
+
+func (parts *multipartParts) addReaderPart(header textproto.MIMEHeader, reader io.Reader) (err error) {
+	reader = unwrapReader(reader)
+	switch r := reader.(type) {
+	case *bytes.Buffer:
+		// marshaled parts are buffered already
+		reader = bytes.NewReader(r.Bytes())
+	case *os.File:
+		// fail early if file is closed
+		if _, err = r.Read(nil); err != nil {
+			return
+		}
+	}
+
+	part := &multipartPart{header: header, size: readerLen(reader)}
+	readerAt, readableAt := reader.(io.ReaderAt)
+	seeker, seekable := reader.(io.Seeker)
+	closer, closable := reader.(io.Closer)
+	switch {
+	case closable:
+		part.closer = closer
+		part.open = func() (io.Reader, error) {
+			return reader, nil
+		}
+	case readableAt && seekable:
+		// every attempt reads its own section, attempts never share an offset
+		var offset, end int64
+		if offset, err = seeker.Seek(0, io.SeekCurrent); err == nil {
+			if end, err = seeker.Seek(0, io.SeekEnd); err == nil {
+				_, err = seeker.Seek(offset, io.SeekStart)
+			}
+		}
+		if err != nil {
+			return
+		}
+		size := end - offset
+		part.size, part.rewindable = size, true
+		part.open = func() (io.Reader, error) {
+			return io.NewSectionReader(readerAt, offset, size), nil
+		}
+	default:
+		part.open = func() (io.Reader, error) {
+			return reader, nil
+		}
+	}
+	parts.parts = append(parts.parts, part)
+	return
+}
+
+// file is opened when it is written, and closed after that
+func (parts *multipartParts) addFile(key, path string) (err error) {
+	var file *os.File
+	var info os.FileInfo
+	if file, err = os.Open(path); err == nil {
+		info, err = file.Stat()
+		file.Close()
+	}
+
+	if err == nil {
+		parts.parts = append(parts.parts, &multipartPart{
+			header:     fileHeader(key, filepath.Base(path), ZeroStr, nil),
+			size:       info.Size(),
+			rewindable: true,
+			open: func() (io.Reader, error) {
+				return os.Open(path)
+			},
+		})
+	}
+	return
+}
+
+// reader of part is added like addReader
+func (parts *multipartParts) addFilePart(key string, part *FilePart) error {
+	return parts.addReaderPart(fileHeader(key, part.Name, part.ContentType, part.Header), part.Reader)
+}
+
+// filename is omitted if it is empty
+func fileHeader(key, filename, contentType string, custom http.Header) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	for key, values := range custom {
+		header[key] = values
+	}
+
+	disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(key))
+	if filename != ZeroStr {
+		disposition += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(filename))
+	}
+	header.Set(headers.HeaderContentDisposition, disposition)
+
+	if contentType != ZeroStr {
+		header.Set(headers.HeaderContentType, contentType)
+	} else if header.Get(headers.HeaderContentType) == ZeroStr {
+		header.Set(headers.HeaderContentType, headers.MIMEOctetStream)
+	}
+	return header
+}
+
+// UnknownLength if size of any part is unknown
+func (parts *multipartParts) length() int64 {
+	var count counter
+	writer := parts.newWriter(&count)
+	for _, part := range parts.parts {
+		if part.size == UnknownLength {
+			return UnknownLength
+		}
+		writer.CreatePart(part.header)
+		count += counter(part.size)
+	}
+	writer.Close()
+	return int64(count)
+}
+
+// parts not written are abandoned if it fails
+func (parts *multipartParts) write(writer io.Writer) (err error) {
+	multipartWriter := parts.newWriter(writer)
+	for i, part := range parts.parts {
+		if err = part.writeTo(multipartWriter); err != nil {
+			abandonParts(parts.parts[i+1:])
+			break
+		}
+	}
+
+	if err == nil {
+		err = multipartWriter.Close()
+	}
+	return
+}
+
+// reader is closed after written
+func (part *multipartPart) writeTo(multipartWriter *multipart.Writer) (err error) {
+	var partWriter io.Writer
+	var reader io.Reader
+	if partWriter, err = multipartWriter.CreatePart(part.header); err != nil {
+		part.abandon()
+		return
+	}
+
+	if reader, err = part.open(); err == nil {
+		_, err = io.Copy(partWriter, reader)
+		if x, ok := reader.(io.Closer); ok {
+			x.Close()
+		}
+	}
+	return
+}
+
+func (part *multipartPart) abandon() {
+	if part.closer != nil {
+		part.closer.Close()
+	}
+}
+
+func abandonParts(parts []*multipartPart) {
+	for _, part := range parts {
+		part.abandon()
+	}
+}
+
+func (parts *multipartParts) pipe() io.ReadCloser {
+	reader, writer := io.Pipe()
+	return &pipeBody{parts: parts, reader: reader, writer: writer}
+}
+
+func (body *pipeBody) Read(p []byte) (int, error) {
+	body.once.Do(func() {
+		go func() {
+			body.writer.CloseWithError(body.parts.write(body.writer))
+		}()
+	})
+	return body.reader.Read(p)
+}
+
+// stop writing, or close readers of parts if it is never read
+func (body *pipeBody) Close() error {
+	body.once.Do(func() {
+		abandonParts(body.parts.parts)
+	})
+	return body.reader.Close()
+}
+
+func (count *counter) Write(p []byte) (int, error) {
+	*count += counter(len(p))
+	return len(p), nil
+}
+
+func unwrapReader(reader io.Reader) io.Reader {
+	if impl, ok := reader.(*ReaderImpl); ok {
+		return impl.reader
+	}
+	return reader
+}
+
+// UnknownLength if it cannot be computed
+func readerLen(reader io.Reader) (length int64) {
+	length = UnknownLength
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		length = int64(r.Len())
+	case *os.File:
+		if info, err := r.Stat(); err == nil && info.Mode().IsRegular() {
+			if offset, err := r.Seek(0, io.SeekCurrent); err == nil {
+				length = info.Size() - offset
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,279 @@
+package gotten
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// states of circuit breaker
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+// keys of circuit breaker
+const (
+	BreakByFunction = "function"
+	BreakByHost     = "host"
+)
+
+// defaults of CircuitBreaker
+const (
+	DefaultBreakerWindow      = 10 * time.Second
+	DefaultBreakerMinRequests = 10
+	DefaultBreakerOpenTimeout = 30 * time.Second
+)
+
+type (
+	BreakerState int
+
+	// CircuitBreaker configures breakers set by Builder.SetCircuitBreaker, a breaker is created for every key
+	CircuitBreaker struct {
+		By      string  // BreakByFunction (Default) or BreakByHost
+		Failure Checker // response is a failure if it checks (Default: 5xx); errors other than cancellation are failures
+
+		// trip after so many consecutive failures, 0 means never
+		ConsecutiveFailures int
+
+		// trip if failures / requests in Window reaches FailureRate after MinRequests, 0 means never
+		FailureRate float64
+		MinRequests int           // Default: DefaultBreakerMinRequests
+		Window      time.Duration // Default: DefaultBreakerWindow
+
+		OpenTimeout      time.Duration // Default: DefaultBreakerOpenTimeout, then the breaker is half-open
+		HalfOpenRequests int           // probes of half-open breaker (Default: 1), it is closed after all of them succeed
+
+		// called instead of returning CircuitOpenError if it is not nil
+		Fallback func(req *http.Request, err *CircuitOpenError) (*http.Response, error)
+
+		// called after state of breaker of key changes
+		OnStateChange func(key string, from, to BreakerState)
+	}
+
+	// CircuitOpenError is returned immediately while breaker is open, or half-open and all probes are in flight
+	CircuitOpenError struct {
+		Key        string // "Service.Function", or host
+		State      BreakerState
+		RetryAfter time.Duration // until the breaker is half-open; 0 if it is half-open
+	}
+
+	breaker struct {
+		key    string
+		config *CircuitBreaker
+
+		mutex          sync.Mutex
+		state          BreakerState
+		failures       int // consecutive
+		requests       int // of window
+		windowFailures int
+		windowStart    time.Time
+		openedAt       time.Time
+		probes         int // of half-open
+		successes      int // of half-open
+	}
+
+	// breakers of a Creator, created on the first request of key
+	breakers struct {
+		config   *CircuitBreaker
+		mutex    sync.Mutex
+		breakers map[string]*breaker
+	}
+)
+
+// outcomes of a request observed by breaker
+const (
+	outcomeSuccess = iota
+	outcomeFailure
+	outcomeIgnored // canceled or limited, the request is not sent or its result is unknown
+)
+
+func (state BreakerState) String() string {
+	switch state {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(state))
+}
+
+func (err *CircuitOpenError) Error() string {
+	message := fmt.Sprintf(CircuitOpen+": %s(%s)", err.Key, err.State)
+	if err.RetryAfter > 0 {
+		message += fmt.Sprintf(", retry after %s", err.RetryAfter)
+	}
+	return message
+}
+
+// nil if config is nil
+func newBreakers(config *CircuitBreaker) (b *breakers) {
+	if config != nil {
+		filled := *config
+		if filled.By == ZeroStr {
+			filled.By = BreakByFunction
+		}
+		if filled.Failure == nil {
+			filled.Failure = CheckerFunc(func(resp *http.Response) bool {
+				return resp.StatusCode >= http.StatusInternalServerError
+			})
+		}
+		if filled.MinRequests <= 0 {
+			filled.MinRequests = DefaultBreakerMinRequests
+		}
+		if filled.Window <= 0 {
+			filled.Window = DefaultBreakerWindow
+		}
+		if filled.OpenTimeout <= 0 {
+			filled.OpenTimeout = DefaultBreakerOpenTimeout
+		}
+		if filled.HalfOpenRequests <= 0 {
+			filled.HalfOpenRequests = 1
+		}
+		b = &breakers{config: &filled, breakers: make(map[string]*breaker)}
+	}
+	return
+}
+
+func (b *breakers) get(key string) *breaker {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	br, ok := b.breakers[key]
+	if !ok {
+		br = &breaker{key: key, config: b.config, windowStart: time.Now()}
+		b.breakers[key] = br
+	}
+	return br
+}
+
+// reject requests while breaker of the function or host is open
+func (b *breakers) withBreaker(info *FuncInfo, next doFunc) doFunc {
+	if b == nil {
+		return next
+	}
+
+	return func(req *http.Request) (resp *http.Response, err error) {
+		key := info.Service + "." + info.Name
+		if b.config.By == BreakByHost {
+			key = req.URL.Host
+		}
+		br := b.get(key)
+
+		probe, openErr := br.allow()
+		if openErr != nil {
+			if b.config.Fallback != nil {
+				return b.config.Fallback(req, openErr)
+			}
+			return nil, openErr
+		}
+
+		resp, err = next(req)
+		outcome := outcomeSuccess
+		var limitErr *LimitError
+		switch {
+		case err != nil && (errors.Is(err, context.Canceled) || errors.As(err, &limitErr)):
+			outcome = outcomeIgnored
+		case err != nil || b.config.Failure.Check(resp):
+			outcome = outcomeFailure
+		}
+		br.observe(probe, outcome)
+		return
+	}
+}
+
+// probe is true if the request is a probe of half-open breaker
+func (br *breaker) allow() (probe bool, err *CircuitOpenError) {
+	br.mutex.Lock()
+	from := br.state
+	now := time.Now()
+	if br.state == StateOpen {
+		if wait := br.openedAt.Add(br.config.OpenTimeout).Sub(now); wait > 0 {
+			err = &CircuitOpenError{Key: br.key, State: StateOpen, RetryAfter: wait}
+		} else {
+			br.transit(StateHalfOpen, now)
+		}
+	}
+	if br.state == StateHalfOpen {
+		if br.probes < br.config.HalfOpenRequests {
+			br.probes++
+			probe = true
+		} else {
+			err = &CircuitOpenError{Key: br.key, State: StateHalfOpen}
+		}
+	}
+	to := br.state
+	br.mutex.Unlock()
+	br.notify(from, to)
+	return
+}
+
+// results of requests allowed before the breaker is open or half-open are ignored by them
+func (br *breaker) observe(probe bool, outcome int) {
+	br.mutex.Lock()
+	from := br.state
+	now := time.Now()
+	switch br.state {
+	case StateHalfOpen:
+		if !probe {
+			break
+		}
+		switch outcome {
+		case outcomeIgnored:
+			br.probes--
+		case outcomeFailure:
+			br.transit(StateOpen, now)
+		default:
+			if br.successes++; br.successes >= br.config.HalfOpenRequests {
+				br.transit(StateClosed, now)
+			}
+		}
+	case StateClosed:
+		if outcome == outcomeIgnored {
+			break
+		}
+		if now.Sub(br.windowStart) > br.config.Window {
+			br.requests, br.windowFailures, br.windowStart = 0, 0, now
+		}
+		br.requests++
+		if outcome == outcomeFailure {
+			br.failures++
+			br.windowFailures++
+		} else {
+			br.failures = 0
+		}
+		if br.config.ConsecutiveFailures > 0 && br.failures >= br.config.ConsecutiveFailures ||
+			br.config.FailureRate > 0 && br.requests >= br.config.MinRequests &&
+				float64(br.windowFailures)/float64(br.requests) >= br.config.FailureRate {
+			br.transit(StateOpen, now)
+		}
+	}
+	to := br.state
+	br.mutex.Unlock()
+	br.notify(from, to)
+}
+
+// must be called with mutex locked
+func (br *breaker) transit(to BreakerState, now time.Time) {
+	br.state = to
+	switch to {
+	case StateOpen:
+		br.openedAt = now
+	case StateHalfOpen:
+		br.probes, br.successes = 0, 0
+	case StateClosed:
+		br.failures, br.requests, br.windowFailures, br.windowStart = 0, 0, 0, now
+	}
+}
+
+// called without mutex locked
+func (br *breaker) notify(from, to BreakerState) {
+	if from != to && br.config.OnStateChange != nil {
+		br.config.OnStateChange(br.key, from, to)
+	}
+}
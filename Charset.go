@@ -0,0 +1,85 @@
+package gotten
+
+import (
+	"encoding/xml"
+	"github.com/Hexilee/gotten/headers"
+	"golang.org/x/net/html/charset"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	CharsetUTF8 = "utf-8"
+)
+
+type (
+	// transcodeReader closes the origin body
+	transcodeReader struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+// Transcode wraps unmarshaler, transcoding body into UTF-8 by charset of Content-Type
+func Transcode(unmarshaler ReadUnmarshaler) ReadUnmarshaler {
+	return ReadUnmarshalFunc(func(reader io.ReadCloser, header http.Header, v interface{}) (err error) {
+		label := contentCharset(header)
+		if label != ZeroStr && !isUTF8(label) {
+			var utf8Reader io.Reader
+			if utf8Reader, err = charset.NewReaderLabel(label, reader); err != nil {
+				reader.Close()
+				return
+			}
+			reader = &transcodeReader{utf8Reader, reader}
+		}
+		return unmarshaler.Unmarshal(reader, header, v)
+	})
+}
+
+// TranscodeHTML wraps unmarshaler, transcoding body into UTF-8 by charset of Content-Type,
+// BOM or meta tags in the first 1024 bytes
+func TranscodeHTML(unmarshaler ReadUnmarshaler) ReadUnmarshaler {
+	return ReadUnmarshalFunc(func(reader io.ReadCloser, header http.Header, v interface{}) (err error) {
+		var utf8Reader io.Reader
+		if utf8Reader, err = charset.NewReader(reader, header.Get(headers.HeaderContentType)); err != nil {
+			reader.Close()
+			return
+		}
+		return unmarshaler.Unmarshal(&transcodeReader{utf8Reader, reader}, header, v)
+	})
+}
+
+// UnmarshalXML decodes body in charset of Content-Type or XML declaration
+func UnmarshalXML(reader io.ReadCloser, header http.Header, v interface{}) (err error) {
+	defer reader.Close()
+	var decoder *xml.Decoder
+	if label := contentCharset(header); label != ZeroStr {
+		// charset of Content-Type takes precedence over XML declaration
+		var utf8Reader io.Reader
+		if utf8Reader, err = charset.NewReaderLabel(label, reader); err != nil {
+			return
+		}
+		decoder = xml.NewDecoder(utf8Reader)
+		decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		}
+	} else {
+		decoder = xml.NewDecoder(reader)
+		decoder.CharsetReader = charset.NewReaderLabel
+	}
+	return decoder.Decode(v)
+}
+
+func contentCharset(header http.Header) (label string) {
+	if _, params, err := mime.ParseMediaType(header.Get(headers.HeaderContentType)); err == nil {
+		label = strings.TrimSpace(params["charset"])
+	}
+	return
+}
+
+func isUTF8(label string) bool {
+	label = strings.ToLower(label)
+	return label == CharsetUTF8 || label == "utf8"
+}
@@ -2,8 +2,10 @@ package gotten
 
 import (
 	"github.com/Hexilee/gotten/headers"
+	"mime"
 	"net/http"
 	"net/textproto"
+	"sort"
 )
 
 type (
@@ -18,6 +20,18 @@ type (
 		Check(*http.Response) bool
 	}
 
+	// AcceptChecker knows which content types it accepts,
+	// Creator generates Accept header by them
+	AcceptChecker interface {
+		Checker
+		Accept() []string
+	}
+
+	acceptChecker struct {
+		CheckerFunc
+		accept []string
+	}
+
 	HeaderSet map[string]map[string]bool
 	StatusSet map[int]bool
 )
@@ -26,6 +40,14 @@ func (fn CheckerFunc) Check(resp *http.Response) bool {
 	return fn(resp)
 }
 
+func NewAcceptChecker(checker CheckerFunc, contentTypes ...string) AcceptChecker {
+	return &acceptChecker{checker, contentTypes}
+}
+
+func (checker acceptChecker) Accept() []string {
+	return checker.accept
+}
+
 func (set HeaderSet) add(key string, values ...string) {
 	key = textproto.CanonicalMIMEHeaderKey(key)
 	for _, value := range values {
@@ -94,6 +116,19 @@ func (factory *CheckerFactory) headerChecker(response *http.Response) (ok bool)
 	return
 }
 
+// content types in WhenContentType; "*/*" if there is no condition
+func (factory *CheckerFactory) accept() (contentTypes []string) {
+	if factory.headerSet == nil && factory.statusSet == nil {
+		contentTypes = []string{MediaRangeAll}
+	} else if values := factory.headerSet[headers.HeaderContentType]; values != nil {
+		for value := range values {
+			contentTypes = append(contentTypes, value)
+		}
+		sort.Strings(contentTypes)
+	}
+	return
+}
+
 func (factory *CheckerFactory) Create() (checker CheckerFunc) {
 	flag := 1
 	if factory.statusSet != nil {
@@ -121,6 +156,24 @@ func (factory *CheckerFactory) Create() (checker CheckerFunc) {
 	return checker
 }
 
+// CreateAccept creates a checker accepting content types in WhenContentType, "*/*" if there is no condition;
+// Creator generates Accept header by it
+func (factory *CheckerFactory) CreateAccept() AcceptChecker {
+	return NewAcceptChecker(factory.Create(), factory.accept()...)
+}
+
+// WhenMediaType checks the media type of Content-Type, ignoring parameters like charset
+func WhenMediaType(mediaTypes ...string) AcceptChecker {
+	set := make(map[string]bool)
+	for _, mediaType := range mediaTypes {
+		set[mediaType] = true
+	}
+	return NewAcceptChecker(func(resp *http.Response) bool {
+		mediaType, _, err := mime.ParseMediaType(resp.Header.Get(headers.HeaderContentType))
+		return err == nil && set[mediaType]
+	}, mediaTypes...)
+}
+
 func Any(_ *http.Response) bool {
 	return true
 }
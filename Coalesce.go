@@ -0,0 +1,147 @@
+package gotten
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	// separator of headers in `coalesce` tag, like `coalesce:"Authorization, Accept-Language"`
+	CoalesceSeparator = ","
+)
+
+type (
+	// identical GET calls of a function in flight share one upstream request
+	coalescer struct {
+		headers []string // values of them are parts of the key
+		mutex   sync.Mutex
+		calls   map[string]*coalescedCall
+	}
+
+	coalescedCall struct {
+		done    chan struct{}
+		cancel  context.CancelFunc
+		waiters int // guarded by mutex of coalescer, the upstream request is canceled if all of them give up
+		stats   *HedgeStats // of the upstream request, copied to every caller
+		resp    *http.Response
+		body    []byte
+		err     error
+	}
+)
+
+// `coalesce` tag of function enables coalescing, its value lists headers distinguishing calls besides url;
+// nil if the tag is absent
+func newCoalescer(tag reflect.StructTag) (c *coalescer) {
+	if raw, ok := tag.Lookup(KeyCoalesce); ok {
+		c = &coalescer{calls: make(map[string]*coalescedCall)}
+		for _, key := range strings.Split(raw, CoalesceSeparator) {
+			if key = strings.TrimSpace(key); key != ZeroStr {
+				c.headers = append(c.headers, http.CanonicalHeaderKey(key))
+			}
+		}
+	}
+	return
+}
+
+func (c *coalescer) key(req *http.Request) string {
+	var builder strings.Builder
+	builder.WriteString(req.URL.String())
+	for _, key := range c.headers {
+		builder.WriteString("\n" + key + ":" + strings.Join(req.Header.Values(key), CoalesceSeparator))
+	}
+	return builder.String()
+}
+
+// the first caller starts the request and the response is buffered, then every caller gets a copy of it;
+// the request is detached from context of the first caller, every caller waits until its own context is done.
+// coalescing is inside logger, metrics, HAR and progress, so every caller is logged, counted and reported on its own;
+// the upstream request keeps context values of the first caller, but its HedgeStats are copied to every caller
+func (c *coalescer) withCoalesce(next doFunc) doFunc {
+	if c == nil {
+		return next
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet {
+			return next(req)
+		}
+
+		key := c.key(req)
+		c.mutex.Lock()
+		call, shared := c.calls[key]
+		if !shared {
+			stats := new(HedgeStats)
+			ctx, cancel := context.WithCancel(context.WithoutCancel(withHedgeStats(req, stats).Context()))
+			call = &coalescedCall{done: make(chan struct{}), cancel: cancel, stats: stats}
+			c.calls[key] = call
+			go c.do(key, call, next, req.WithContext(ctx))
+		}
+		call.waiters++
+		c.mutex.Unlock()
+
+		select {
+		case <-call.done:
+			return call.response(req)
+		case <-req.Context().Done():
+			c.leave(key, call)
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// done is closed even if next panics
+func (c *coalescer) do(key string, call *coalescedCall, next doFunc, req *http.Request) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			call.resp, call.err = nil, CoalescedCallPanickedError(recovered)
+		}
+		c.forget(key, call)
+		call.cancel()
+		close(call.done)
+	}()
+
+	if call.resp, call.err = next(req); call.err == nil {
+		call.body, call.err = ioutil.ReadAll(call.resp.Body)
+		call.resp.Body.Close()
+	}
+}
+
+// callers come later start a new call
+func (c *coalescer) forget(key string, call *coalescedCall) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+}
+
+// cancel the upstream request if every caller gives up
+func (c *coalescer) leave(key string, call *coalescedCall) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if call.waiters--; call.waiters == 0 {
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+		call.cancel()
+	}
+}
+
+// independent header and body for every caller
+func (call *coalescedCall) response(req *http.Request) (resp *http.Response, err error) {
+	if stats, _ := req.Context().Value(hedgeStatsKey{}).(*HedgeStats); stats != nil {
+		*stats = *call.stats
+	}
+	if err = call.err; err == nil {
+		copied := *call.resp
+		copied.Header = call.resp.Header.Clone()
+		copied.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+		resp = &copied
+	}
+	return
+}
@@ -2,14 +2,16 @@ package gotten
 
 import (
 	"encoding/json"
-	"encoding/xml"
 	"errors"
 	"github.com/Hexilee/gotten/headers"
 	"github.com/Hexilee/unhtml"
 	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"reflect"
+	"time"
 )
 
 type (
@@ -19,6 +21,27 @@ type (
 		headers      http.Header
 		client       Client
 		unmarshalers ConditionalUnmarshalers
+		metrics      Metrics
+		logger       *slog.Logger
+		logBodyLimit int
+		redacted     map[string]bool
+		har          *HARRecorder
+		progress     Progress
+		marshalers   BodyMarshalers
+		registerErr  error
+		endpoints    []endpointSpec
+		balancer     Balancer
+		maxFailures  int
+		ejectTime    time.Duration
+		healthPath   string
+		healthEvery  time.Duration
+		hedge        time.Duration
+		boundary     string
+		limit        limitSpec
+		hostLimits   map[string]limitSpec
+		failFast     bool
+		adaptive     bool
+		breaker      *CircuitBreaker
 	}
 
 	Creator struct {
@@ -27,6 +50,35 @@ type (
 		headers      http.Header
 		client       Client
 		unmarshalers ConditionalUnmarshalers
+		metrics      Metrics
+		logger       *slog.Logger
+		logBodyLimit int
+		redacted     map[string]bool
+		har          *HARRecorder
+		progress     Progress
+		marshalers   BodyMarshalers
+		boundary     string        // of multipart bodies, random for every call if empty
+		accept       string        // generated by unmarshalers
+		pool         *endpointPool // nil if no endpoint is added
+		hedge        time.Duration
+		limits       *limits
+		breakers     *breakers // nil if no circuit breaker is set
+	}
+
+	// FuncInfo describes a service function implemented by Creator
+	FuncInfo struct {
+		Service string // name of the service struct
+		Name    string // name of the function field
+		Method  string
+		Path    string // path template, like "/post/{year}"
+
+		accept    string        // from tag
+		headers   http.Header   // of embedded Service
+		timeout   time.Duration // of embedded Service
+		hedge     time.Duration // 0 if not hedged
+		coalescer *coalescer    // nil if not coalesced
+		limiter   *limiter      // nil if not limited
+		sensitive *sensitiveKeys
 	}
 
 	ConditionalUnmarshaler struct {
@@ -35,32 +87,31 @@ type (
 	}
 
 	ConditionalUnmarshalers []*ConditionalUnmarshaler
+
+	// the signature of Client.Do
+	doFunc func(req *http.Request) (*http.Response, error)
 )
 
 var (
 	DefaultUnmarshalers = []*ConditionalUnmarshaler{
 		{
-			NewReaderAdapter(UnmarshalAdapter(json.Unmarshal)),
-			new(CheckerFactory).WhenContentType(
-				headers.MIMEApplicationJSON,
-				headers.MIMEApplicationJSONCharsetUTF8,
-			).Create(),
+			Transcode(NewReaderAdapter(UnmarshalAdapter(json.Unmarshal))),
+			WhenMediaType(headers.MIMEApplicationJSON),
 		},
 		{
-			NewReaderAdapter(UnmarshalAdapter(xml.Unmarshal)),
-			new(CheckerFactory).WhenContentType(
-				headers.MIMEApplicationXML,
-				headers.MIMEApplicationXMLCharsetUTF8,
-				headers.MIMETextXML,
-				headers.MIMETextXMLCharsetUTF8,
-			).Create(),
+			ReadUnmarshalFunc(UnmarshalXML),
+			WhenMediaType(headers.MIMEApplicationXML, headers.MIMETextXML),
 		},
 		{
-			NewReaderAdapter(UnmarshalAdapter(unhtml.Unmarshal)),
+			NewReaderAdapter(UnmarshalAdapter(UnmarshalProtobuf)),
 			new(CheckerFactory).WhenContentType(
-				headers.MIMETextHTML,
-				headers.MIMETextHTMLCharsetUTF8,
-			).Create(),
+				headers.MIMEApplicationXProtobuf,
+				headers.MIMEApplicationProtobuf,
+			).CreateAccept(),
+		},
+		{
+			TranscodeHTML(NewReaderAdapter(UnmarshalAdapter(unhtml.Unmarshal))),
+			WhenMediaType(headers.MIMETextHTML),
 		},
 	}
 )
@@ -70,6 +121,9 @@ func NewBuilder() *Builder {
 		cookies:      make([]*http.Cookie, 0),
 		headers:      make(http.Header),
 		unmarshalers: make(ConditionalUnmarshalers, 0),
+		redacted:     newRedactedHeaders(DefaultRedactedHeaders),
+		marshalers:   DefaultMarshalers(),
+		hostLimits:   make(map[string]limitSpec),
 	}
 }
 
@@ -78,6 +132,89 @@ func (builder *Builder) SetBaseUrl(url string) *Builder {
 	return builder
 }
 
+// add an endpoint to share requests with base url, weight is used by Weighted and ConsistentHash (Default: 1);
+// base url is the first endpoint if it is set
+func (builder *Builder) AddEndpoint(url string, weight int) *Builder {
+	if weight < 1 {
+		weight = 1
+	}
+	builder.endpoints = append(builder.endpoints, endpointSpec{url, weight})
+	return builder
+}
+
+// pick endpoint for every request by balancer (Default: RoundRobin())
+func (builder *Builder) SetBalancer(balancer Balancer) *Builder {
+	builder.balancer = balancer
+	return builder
+}
+
+// eject an endpoint for ejectTime after maxFailures consecutive errors or 5xx responses
+func (builder *Builder) SetOutlierEjection(maxFailures int, ejectTime time.Duration) *Builder {
+	builder.maxFailures = maxFailures
+	builder.ejectTime = ejectTime
+	return builder
+}
+
+// GET path of every endpoint every interval, endpoint not responding 2xx is unavailable until it recovers;
+// call Creator.StopHealthCheck to stop it
+func (builder *Builder) SetHealthCheck(path string, interval time.Duration) *Builder {
+	builder.healthPath = path
+	builder.healthEvery = interval
+	return builder
+}
+
+// hedge idempotent calls of every function by delay, unless it is covered by `hedge` tag of function
+func (builder *Builder) SetHedge(delay time.Duration) *Builder {
+	builder.hedge = delay
+	return builder
+}
+
+// limit requests of the creator to count per period
+func (builder *Builder) SetRateLimit(count int, per time.Duration) *Builder {
+	builder.limit.count, builder.limit.per = count, per
+	return builder
+}
+
+// limit in-flight requests of the creator, a request is in flight until its response body is closed
+func (builder *Builder) SetConcurrencyLimit(concurrency int) *Builder {
+	builder.limit.concurrency = concurrency
+	return builder
+}
+
+// limit requests to host, like "api.github.com" or "localhost:8080", to count per period
+func (builder *Builder) SetHostRateLimit(host string, count int, per time.Duration) *Builder {
+	spec := builder.hostLimits[host]
+	spec.count, spec.per = count, per
+	builder.hostLimits[host] = spec
+	return builder
+}
+
+// limit in-flight requests to host
+func (builder *Builder) SetHostConcurrencyLimit(host string, concurrency int) *Builder {
+	spec := builder.hostLimits[host]
+	spec.concurrency = concurrency
+	builder.hostLimits[host] = spec
+	return builder
+}
+
+// return *LimitError instead of waiting if a limit is exceeded
+func (builder *Builder) SetLimitFailFast(failFast bool) *Builder {
+	builder.failFast = failFast
+	return builder
+}
+
+// pause requests to a host by Retry-After, or by X-RateLimit-Reset if X-RateLimit-Remaining is 0
+func (builder *Builder) SetAdaptiveRateLimit(adaptive bool) *Builder {
+	builder.adaptive = adaptive
+	return builder
+}
+
+// break calls of every function or host by breaker, see CircuitBreaker for defaults
+func (builder *Builder) SetCircuitBreaker(breaker CircuitBreaker) *Builder {
+	builder.breaker = &breaker
+	return builder
+}
+
 func (builder *Builder) AddCookie(cookie *http.Cookie) *Builder {
 	builder.cookies = append(builder.cookies, cookie)
 	return builder
@@ -120,30 +257,147 @@ func (builder *Builder) SetClient(client Client) *Builder {
 	return builder
 }
 
+func (builder *Builder) SetMetrics(metrics Metrics) *Builder {
+	builder.metrics = metrics
+	return builder
+}
+
+// log every call of services by logger
+func (builder *Builder) SetLogger(logger *slog.Logger) *Builder {
+	builder.logger = logger
+	return builder
+}
+
+// log request and response bodies up to limit bytes; 0 means not to log bodies.
+// a call returning a response is logged only after its body is read to EOF or closed,
+// so a call whose body is never closed, which leaks the connection as well, is never logged
+func (builder *Builder) SetLogBodyLimit(limit int) *Builder {
+	builder.logBodyLimit = limit
+	return builder
+}
+
+// headers whose values are masked in logs, replacing DefaultRedactedHeaders
+func (builder *Builder) SetRedactedHeaders(keys ...string) *Builder {
+	builder.redacted = newRedactedHeaders(keys)
+	return builder
+}
+
+// record every call into writer as a HTTP Archive, call Creator.CloseHAR to complete it;
+// an entry is written after the response body is read to the end or closed, with at most HARBodyLimit bytes of it
+func (builder *Builder) RecordHAR(writer io.Writer) *Builder {
+	builder.har = NewHARRecorder(writer)
+	return builder
+}
+
+// fixed boundary of multipart bodies, so they are reproducible; it is random for every call by default
+func (builder *Builder) SetBoundary(boundary string) *Builder {
+	builder.boundary = boundary
+	return builder
+}
+
+// report progress of uploading and downloading of every call
+func (builder *Builder) SetProgress(progress Progress) *Builder {
+	builder.progress = progress
+	return builder
+}
+
+// register a body type for params fields tagged `type:"<typeName>"`, or replace a default one;
+// the body is sent as contentType, or embedded into form and multipart like TypeJSON
+func (builder *Builder) RegisterMarshaler(typeName, contentType string, marshalFunc MarshalFunc) *Builder {
+	switch {
+	case reservedValueType(typeName):
+		builder.registerErr = ReservedValueTypeError(typeName)
+	case reservedContentType(contentType):
+		builder.registerErr = ReservedContentTypeError(contentType)
+	default:
+		builder.marshalers[typeName] = NewBodyMarshaler(contentType, marshalFunc)
+	}
+	return builder
+}
+
 func (builder *Builder) Build() (creator *Creator, err error) {
-	if builder.baseUrl == "" {
+	rawBaseUrl, endpoints := builder.baseUrl, builder.endpoints
+	if rawBaseUrl != "" && len(endpoints) > 0 {
+		endpoints = append([]endpointSpec{{rawBaseUrl, 1}}, endpoints...)
+	} else if rawBaseUrl == "" && len(endpoints) > 0 {
+		rawBaseUrl = endpoints[0].url
+	}
+
+	if rawBaseUrl == "" {
 		err = errors.New(BaseUrlCannotBeEmpty)
 	}
 
+	if err == nil {
+		err = builder.registerErr
+	}
+
+	if err == nil && builder.boundary != ZeroStr {
+		if multipart.NewWriter(nil).SetBoundary(builder.boundary) != nil {
+			err = InvalidBoundaryError(builder.boundary)
+		}
+	}
+
 	if err == nil {
 		var baseUrl *url.URL
-		baseUrl, err = url.Parse(builder.baseUrl)
+		baseUrl, err = url.Parse(rawBaseUrl)
 		if err == nil {
 			if builder.client == nil {
 				builder.client = &http.Client{}
 			}
+			unmarshalers := append(builder.unmarshalers, DefaultUnmarshalers...)
 			creator = &Creator{
 				baseUrl:      baseUrl,
 				cookies:      builder.cookies,
 				headers:      builder.headers,
 				client:       builder.client,
-				unmarshalers: append(builder.unmarshalers, DefaultUnmarshalers...),
+				unmarshalers: unmarshalers,
+				accept:       unmarshalers.Accept(),
+				metrics:      builder.metrics,
+				logger:       builder.logger,
+				logBodyLimit: builder.logBodyLimit,
+				redacted:     builder.redacted,
+				har:          builder.har,
+				progress:     builder.progress,
+				marshalers:   builder.marshalers,
+				boundary:     builder.boundary,
+				hedge:        builder.hedge,
+				limits:       newLimits(builder.limit, builder.hostLimits, builder.failFast, builder.adaptive),
+				breakers:     newBreakers(builder.breaker),
+			}
+		}
+
+		if err == nil && len(endpoints) > 0 {
+			balancer := builder.balancer
+			if balancer == nil {
+				balancer = RoundRobin()
+			}
+			creator.pool, err = newEndpointPool(endpoints, balancer, builder.maxFailures, builder.ejectTime)
+			if err == nil {
+				creator.baseUrl = creator.pool.primary
+				if builder.healthPath != ZeroStr && builder.healthEvery > 0 {
+					creator.pool.runHealthCheck(creator.client, builder.healthPath, builder.healthEvery)
+				}
 			}
 		}
 	}
 	return
 }
 
+// Endpoints returns endpoints added by Builder.AddEndpoint, nil if there is no one
+func (creator Creator) Endpoints() (endpoints []*Endpoint) {
+	if creator.pool != nil {
+		endpoints = creator.pool.endpoints
+	}
+	return
+}
+
+// StopHealthCheck stops health check set by Builder.SetHealthCheck
+func (creator Creator) StopHealthCheck() {
+	if creator.pool != nil {
+		creator.pool.stop()
+	}
+}
+
 // func(*params) (*http.Request, error) ||
 // func(*params) (gotten.Response, error)
 func (creator *Creator) Impl(service interface{}) (err error) {
@@ -159,9 +413,18 @@ func (creator *Creator) Impl(service interface{}) (err error) {
 			err = ServiceMustBeStructError(serviceType)
 		}
 
+		var config *serviceConfig
+		if err == nil {
+			config, err = newServiceConfig(serviceType)
+		}
+
 		if err == nil {
 			for i := 0; i < serviceType.NumField(); i++ {
 				field := serviceType.Field(i)
+				if isServiceMarker(field) {
+					continue
+				}
+
 				fieldType := field.Type
 				fieldTag := field.Tag
 				fieldValue := serviceVal.Field(i)
@@ -176,11 +439,19 @@ func (creator *Creator) Impl(service interface{}) (err error) {
 
 				if err == nil {
 					paramsType := fieldType.In(0)
-					varsParser, parseErr := newVarsParser(fieldTag.Get(KeyPath))
+					varsParser, parseErr := newVarsParser(config.path(fieldTag.Get(KeyPath)), creator.marshalers)
 					if err = parseErr; err == nil {
+						varsParser.boundary = creator.boundary
 						err = varsParser.parse(paramsType)
 						if err == nil {
 							method := fieldTag.Get(KeyMethod)
+							info := newFuncInfo(serviceType, field, method, config, varsParser)
+							if info.hedge, err = processHedge(fieldTag, creator.hedge); err != nil {
+								break
+							}
+							if info.limiter, err = processLimit(fieldTag, info); err != nil {
+								break
+							}
 
 							// TODO: add body check for different methods
 							switch method {
@@ -205,9 +476,9 @@ func (creator *Creator) Impl(service interface{}) (err error) {
 							case http.MethodTrace:
 								switch fieldType.Out(0) {
 								case ResponseType:
-									fieldValue.Set(reflect.MakeFunc(fieldType, creator.getCompleteFunc(varsParser, method)))
+									fieldValue.Set(reflect.MakeFunc(fieldType, creator.getCompleteFunc(info, varsParser)))
 								case RequestType:
-									fieldValue.Set(reflect.MakeFunc(fieldType, creator.getRequestFunc(varsParser, method)))
+									fieldValue.Set(reflect.MakeFunc(fieldType, creator.getRequestFunc(info, varsParser)))
 									//default:
 								}
 
@@ -227,7 +498,7 @@ func (creator *Creator) Impl(service interface{}) (err error) {
 }
 
 // for func(*params) (*http.Request, error)
-func (creator Creator) getRequestFunc(varsParser *VarsParser, method string) func([]reflect.Value) []reflect.Value {
+func (creator Creator) getRequestFunc(info *FuncInfo, varsParser *VarsParser) func([]reflect.Value) []reflect.Value {
 	return func(values []reflect.Value) []reflect.Value {
 		results := []reflect.Value{
 			reflect.New(RequestType).Elem(),
@@ -237,6 +508,7 @@ func (creator Creator) getRequestFunc(varsParser *VarsParser, method string) fun
 		setValuesErr := varsCtr.setValues(values[0])
 
 		if setValuesErr != nil {
+			varsCtr.abandon()
 			results[1].Set(reflect.ValueOf(setValuesErr).Convert(ErrorType))
 			return results
 		}
@@ -248,23 +520,26 @@ func (creator Creator) getRequestFunc(varsParser *VarsParser, method string) fun
 		//	return results
 		//}
 
-		var body io.Reader
+		body := new(requestBody)
 		contentType := varsCtr.getContentType()
 
 		if contentType != ZeroStr {
 			body, err = varsCtr.getBody()
 			if err != nil {
+				varsCtr.abandon()
 				results[1].Set(reflect.ValueOf(err).Convert(ErrorType))
 				return results
 			}
 		}
 
-		req, err := http.NewRequest(method, finalUrl.String(), body)
+		req, err := http.NewRequest(info.Method, finalUrl.String(), body.reader)
 		// err always be nil with checked method and URL
 		//if err != nil {
 		//	results[1].Set(reflect.ValueOf(err).Convert(ErrorType))
 		//	return results
 		//}
+		body.setTo(req)
+		req = withProgressContext(req, varsCtr.getProgress())
 
 		for key, values := range creator.headers {
 			for _, value := range values {
@@ -272,7 +547,17 @@ func (creator Creator) getRequestFunc(varsParser *VarsParser, method string) fun
 			}
 		}
 
-		// cover header of creator
+		// headers of service cover header of creator
+		for key, values := range info.headers {
+			req.Header[key] = append([]string(nil), values...)
+		}
+
+		// accept tag covers header of creator and service
+		if info.accept != ZeroStr {
+			req.Header.Set(headers.HeaderAccept, info.accept)
+		}
+
+		// cover header of creator and accept tag
 		for key, values := range varsCtr.getHeader() {
 			for _, value := range values {
 				req.Header.Set(key, value)
@@ -284,6 +569,11 @@ func (creator Creator) getRequestFunc(varsParser *VarsParser, method string) fun
 			req.Header.Set(headers.HeaderContentType, contentType)
 		}
 
+		// generate Accept if no one sets it
+		if req.Header.Get(headers.HeaderAccept) == ZeroStr && creator.accept != ZeroStr {
+			req.Header.Set(headers.HeaderAccept, creator.accept)
+		}
+
 		// add cookie of creator
 		for _, cookie := range creator.cookies {
 			req.AddCookie(cookie)
@@ -300,32 +590,94 @@ func (creator Creator) getRequestFunc(varsParser *VarsParser, method string) fun
 }
 
 // for func(*params) (gotten.Response, error)
-func (creator Creator) getCompleteFunc(varsParser *VarsParser, method string) func([]reflect.Value) []reflect.Value {
+func (creator Creator) getCompleteFunc(info *FuncInfo, varsParser *VarsParser) func([]reflect.Value) []reflect.Value {
+	do := creator.chain(info)
 	return func(values []reflect.Value) []reflect.Value {
-		results := creator.getRequestFunc(varsParser, method)(values)
+		results := creator.getRequestFunc(info, varsParser)(values)
 		req := results[0].Interface().(*http.Request)
 		results[0] = reflect.New(ResponseType).Elem()
 		if results[1].IsNil() {
-			resp, err := creator.client.Do(req)
+			var hedge *HedgeStats
+			if info.hedge > 0 {
+				hedge = new(HedgeStats)
+				req = withHedgeStats(req, hedge)
+			}
+			resp, err := do(req)
 
 			if err != nil {
+				// body may be never read if request is rejected before sent
+				if req.Body != nil {
+					req.Body.Close()
+				}
 				results[1].Set(reflect.ValueOf(err).Convert(ErrorType))
 				return results
 			}
 
 			readUnmarshaler, exist := creator.unmarshalers.Check(resp)
+			if resp.StatusCode == http.StatusNotAcceptable {
+				results[1].Set(reflect.ValueOf(NotAcceptableError(req.Header.Get(headers.HeaderAccept), resp.Header.Get(headers.HeaderContentType))).Convert(ErrorType))
+				results[0].Set(reflect.ValueOf(newResponse(resp, readUnmarshaler, hedge)).Convert(ResponseType))
+				return results
+			}
+
 			if !exist {
 				results[1].Set(reflect.ValueOf(NoUnmarshalerFoundForResponseError(resp)).Convert(ErrorType))
-				results[0].Set(reflect.ValueOf(newResponse(resp, nil)).Convert(ResponseType))
+				results[0].Set(reflect.ValueOf(newResponse(resp, nil, hedge)).Convert(ResponseType))
 				return results
 			}
 
-			results[0].Set(reflect.ValueOf(newResponse(resp, readUnmarshaler)).Convert(ResponseType))
+			results[0].Set(reflect.ValueOf(newResponse(resp, readUnmarshaler, hedge)).Convert(ResponseType))
 		}
 		return results
 	}
 }
 
+// CloseHAR completes the HTTP Archive set by Builder.RecordHAR
+func (creator Creator) CloseHAR() (err error) {
+	if creator.har != nil {
+		err = creator.har.Close()
+	}
+	return
+}
+
+// chain wraps creator.client.Do with middlewares configured in builder, the outermost runs first
+func (creator Creator) chain(info *FuncInfo) doFunc {
+	do := creator.limits.withLimits(info, creator.client.Do)
+	do = creator.breakers.withBreaker(info, do)
+	if creator.pool != nil {
+		do = creator.pool.withBalancer(do)
+	}
+	do = withHedge(info.hedge, do)
+	do = info.coalescer.withCoalesce(do)
+	do = creator.withProgress(do)
+	do = creator.withHAR(info, do)
+	do = creator.withMetrics(info, do)
+	do = creator.withLogger(info, do)
+	do = withTimeout(info.timeout, do)
+	return do
+}
+
+func newFuncInfo(serviceType reflect.Type, field reflect.StructField, method string, config *serviceConfig, varsParser *VarsParser) *FuncInfo {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return &FuncInfo{
+		Service:   serviceType.Name(),
+		Name:      field.Name,
+		Method:    method,
+		Path:      config.path(field.Tag.Get(KeyPath)),
+		accept:    field.Tag.Get(KeyAccept),
+		headers:   config.headers,
+		timeout:   config.timeout,
+		coalescer: newCoalescer(field.Tag),
+		sensitive: varsParser.sensitiveKeys(),
+	}
+}
+
+func NewConditionalUnmarshaler(unmarshaler ReadUnmarshaler, checker Checker) *ConditionalUnmarshaler {
+	return &ConditionalUnmarshaler{unmarshaler, checker}
+}
+
 func (unmarshalers ConditionalUnmarshalers) Check(response *http.Response) (unmarshaler ReadUnmarshaler, exist bool) {
 	for _, conditional := range unmarshalers {
 		if conditional.checker.Check(response) {
@@ -0,0 +1,101 @@
+package gotten
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ToCurl returns a curl command reproducing the request;
+// the body is buffered and restored, so req can still be sent
+func ToCurl(req *http.Request) (cmd string, err error) {
+	var body []byte
+	body, err = bufferRequestBody(req)
+	if err == nil {
+		buf := strings.Builder{}
+		buf.WriteString("curl")
+		method := req.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		// curl sends GET without data and POST with data
+		implied := http.MethodGet
+		if len(body) > 0 {
+			implied = http.MethodPost
+		}
+		if method != implied {
+			buf.WriteString(" -X " + method)
+		}
+
+		buf.WriteString(" " + shellQuote(req.URL.String()))
+
+		keys := make([]string, 0, len(req.Header))
+		for key := range req.Header {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if req.Host != "" && req.Host != req.URL.Host {
+			buf.WriteString(" -H " + shellQuote("Host: "+req.Host))
+		}
+		for _, key := range keys {
+			for _, value := range req.Header[key] {
+				buf.WriteString(" -H " + shellQuote(key+": "+value))
+			}
+		}
+
+		if len(body) > 0 {
+			if isText(body) {
+				buf.WriteString(" --data-binary " + shellQuote(string(body)))
+			} else {
+				// shell arguments cannot carry binary data
+				buf.WriteString(" --data-binary @<(echo " + base64.StdEncoding.EncodeToString(body) + " | base64 -d)")
+			}
+		}
+		cmd = buf.String()
+	}
+	return
+}
+
+// single quotes protect everything except single quote itself
+func shellQuote(str string) string {
+	return "'" + strings.Replace(str, "'", `'\''`, -1) + "'"
+}
+
+// valid utf-8 without NUL
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// reads the whole body and makes it replayable
+func bufferRequestBody(req *http.Request) (body []byte, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	if req.GetBody != nil {
+		reader, getErr := req.GetBody()
+		if getErr == nil {
+			body, err = ioutil.ReadAll(reader)
+			reader.Close()
+			return
+		}
+	}
+
+	body, err = ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err == nil {
+		data := body
+		req.ContentLength = int64(len(data))
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (reader io.ReadCloser, err error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+	return
+}
@@ -25,6 +25,22 @@ const (
 	NoUnmarshalerFoundForResponse = "no unmarshaler found for response"
 	ContentTypeConflict           = "content type conflict: "
 	UnsupportedFuncType           = "function type is not supported"
+	MustPassProtoMessage          = "must pass a proto.Message to unmarshal protobuf"
+	ReservedValueType             = "value type is reserved"
+	ReservedContentType           = "content type is reserved"
+	NotAcceptable                 = "server cannot respond with an acceptable content type"
+	InvalidValidationRule         = "validation rule is invalid"
+	UnsupportedValidationType     = "validation rule is unsupported by field type"
+	ValidationFailed              = "validation failed"
+	InvalidServiceTag             = "tag of Service is invalid"
+	InvalidFuncTag                = "tag of function is invalid"
+	InvalidFieldTag               = "tag of field is invalid"
+	InvalidBoundary               = "boundary of multipart is invalid"
+	InvalidRate                   = "rate is invalid"
+	RateLimitExceeded             = "rate limit exceeded"
+	ConcurrencyLimitExceeded      = "concurrency limit exceeded"
+	CircuitOpen                   = "circuit is open"
+	CoalescedCallPanicked         = "coalesced call panicked"
 )
 
 func MustPassPtrToImplError(p reflect.Type) error {
@@ -93,3 +109,47 @@ func ContentTypeConflictError(former string, latter string) error {
 func UnsupportedFuncTypeError(p reflect.Type) error {
 	return errors.New(UnsupportedFuncType + ": " + p.String())
 }
+
+func MustPassProtoMessageError(p reflect.Type) error {
+	return errors.New(fmt.Sprintf(MustPassProtoMessage+": %v", p))
+}
+
+func ReservedValueTypeError(valueType string) error {
+	return errors.New(ReservedValueType + ": " + valueType)
+}
+
+func ReservedContentTypeError(contentType string) error {
+	return errors.New(ReservedContentType + ": " + contentType)
+}
+
+func NotAcceptableError(accept, contentType string) error {
+	return errors.New(fmt.Sprintf(NotAcceptable+": Accept(%s), Content-Type of response(%s)", accept, contentType))
+}
+
+func InvalidValidationRuleError(field, rule, param string) error {
+	return errors.New(fmt.Sprintf(InvalidValidationRule+": %s(%s) of %s", rule, param, field))
+}
+
+func UnsupportedValidationTypeError(field, rule string, fieldType reflect.Type) error {
+	return errors.New(fmt.Sprintf(UnsupportedValidationType+": %s of %s(%s)", rule, field, fieldType))
+}
+
+func InvalidServiceTagError(key, value string) error {
+	return errors.New(fmt.Sprintf(InvalidServiceTag+": %s:%q", key, value))
+}
+
+func InvalidFuncTagError(key, value string) error {
+	return errors.New(fmt.Sprintf(InvalidFuncTag+": %s:%q", key, value))
+}
+
+func InvalidFieldTagError(field, key, value string) error {
+	return errors.New(fmt.Sprintf(InvalidFieldTag+": %s:%q of %s", key, value, field))
+}
+
+func InvalidBoundaryError(boundary string) error {
+	return errors.New(fmt.Sprintf(InvalidBoundary+": %q", boundary))
+}
+
+func CoalescedCallPanickedError(recovered interface{}) error {
+	return errors.New(fmt.Sprintf(CoalescedCallPanicked+": %v", recovered))
+}
@@ -0,0 +1,315 @@
+package gotten
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/Hexilee/gotten/headers"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	HARVersion = "1.2"
+	HARCreator = "gotten"
+	// at most so many bytes of response body are captured, Content.Size is still the whole size
+	HARBodyLimit = 1 << 20
+)
+
+type (
+	// HARRecorder streams entries to a writer as a HTTP Archive,
+	// the archive is complete only after Close
+	HARRecorder struct {
+		mutex   sync.Mutex
+		writer  io.Writer
+		started bool
+		closed  bool
+	}
+
+	HAREntry struct {
+		StartedDateTime time.Time   `json:"startedDateTime"`
+		Time            float64     `json:"time"` // ms
+		Request         HARRequest  `json:"request"`
+		Response        HARResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         HARTimings  `json:"timings"`
+		Comment         string      `json:"comment,omitempty"`
+	}
+
+	HARRequest struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []HARNameValue `json:"cookies"`
+		Headers     []HARNameValue `json:"headers"`
+		QueryString []HARNameValue `json:"queryString"`
+		PostData    *HARPostData   `json:"postData,omitempty"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int64          `json:"bodySize"`
+	}
+
+	HARResponse struct {
+		Status      int            `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []HARNameValue `json:"cookies"`
+		Headers     []HARNameValue `json:"headers"`
+		Content     HARContent     `json:"content"`
+		RedirectURL string         `json:"redirectURL"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int64          `json:"bodySize"`
+	}
+
+	HARNameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	HARPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding,omitempty"` // "base64" if body is not text
+	}
+
+	// keeps at most HARBodyLimit bytes read by caller, calls record once when it is read to the end or closed
+	harBody struct {
+		io.ReadCloser
+		buf    bytes.Buffer
+		size   int64
+		once   sync.Once
+		record func(body []byte, size int64, err error)
+	}
+
+	HARContent struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+		Comment  string `json:"comment,omitempty"` // TruncatedMark if text is cut at HARBodyLimit
+	}
+
+	HARTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+func NewHARRecorder(writer io.Writer) *HARRecorder {
+	return &HARRecorder{writer: writer}
+}
+
+// Record writes an entry; calls after Close are ignored
+func (recorder *HARRecorder) Record(entry *HAREntry) (err error) {
+	var data []byte
+	data, err = json.Marshal(entry)
+	if err == nil {
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+		if !recorder.closed {
+			err = recorder.writeHead(",")
+			if err == nil {
+				_, err = recorder.writer.Write(data)
+			}
+		}
+	}
+	return
+}
+
+// Close completes the archive, but never closes the writer
+func (recorder *HARRecorder) Close() (err error) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if !recorder.closed {
+		recorder.closed = true
+		if err = recorder.writeHead(""); err == nil {
+			_, err = io.WriteString(recorder.writer, "]}}\n")
+		}
+	}
+	return
+}
+
+// writes the head of archive at the first time, otherwise the separator
+func (recorder *HARRecorder) writeHead(separator string) (err error) {
+	if recorder.started {
+		_, err = io.WriteString(recorder.writer, separator)
+	} else {
+		recorder.started = true
+		_, err = io.WriteString(recorder.writer,
+			`{"log":{"version":"`+HARVersion+`","creator":{"name":"`+HARCreator+`","version":""},"entries":[`)
+	}
+	return
+}
+
+// headers, cookies, query and body are redacted like logs;
+// response body is streamed to caller, the entry is recorded after caller reads it to the end or closes it
+func (creator Creator) withHAR(info *FuncInfo, next doFunc) doFunc {
+	if creator.har == nil {
+		return next
+	}
+
+	return func(req *http.Request) (resp *http.Response, err error) {
+		var reqBody []byte
+		if reqBody, err = bufferRequestBody(req); err != nil {
+			return
+		}
+
+		start := time.Now()
+		resp, err = next(req)
+		wait := time.Since(start)
+		entry := &HAREntry{
+			StartedDateTime: start,
+			Request:         creator.newHARRequest(info, req, reqBody),
+		}
+
+		if err != nil {
+			entry.Comment = err.Error()
+			entry.Response = HARResponse{
+				Cookies:     make([]HARNameValue, 0),
+				Headers:     make([]HARNameValue, 0),
+				HeadersSize: -1,
+			}
+		} else if resp.Body != nil {
+			resp.Body = &harBody{ReadCloser: resp.Body, record: func(body []byte, size int64, readErr error) {
+				if readErr != nil {
+					// the caller gets the error after the partial body
+					entry.Comment = readErr.Error()
+				}
+				entry.Response = creator.newHARResponse(resp, body, size)
+				creator.recordHAR(entry, start, wait)
+			}}
+			return
+		} else {
+			entry.Response = creator.newHARResponse(resp, nil, 0)
+		}
+		creator.recordHAR(entry, start, wait)
+		return
+	}
+}
+
+// recording is for debugging, never fail the call
+func (creator Creator) recordHAR(entry *HAREntry, start time.Time, wait time.Duration) {
+	total := time.Since(start)
+	entry.Time = milliseconds(total)
+	entry.Timings = HARTimings{Wait: milliseconds(wait), Receive: milliseconds(total - wait)}
+	creator.har.Record(entry)
+}
+
+func (creator Creator) newHARRequest(info *FuncInfo, req *http.Request, body []byte) HARRequest {
+	header := creator.redactHeader(req.Header, info.sensitive.header, info.sensitive.cookie)
+	query := req.URL.Query()
+	redactedUrl := *req.URL
+	if len(info.sensitive.query) > 0 {
+		redactedUrl.RawQuery = info.sensitive.redactQuery(query)
+		query = redactedUrl.Query()
+	}
+	harReq := HARRequest{
+		Method:      req.Method,
+		URL:         redactedUrl.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     harHeaders(header),
+		QueryString: make([]HARNameValue, 0),
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	if harReq.HTTPVersion == "" {
+		harReq.HTTPVersion = "HTTP/1.1"
+	}
+	for _, cookie := range (&http.Request{Header: header}).Cookies() {
+		harReq.Cookies = append(harReq.Cookies, HARNameValue{cookie.Name, cookie.Value})
+	}
+	for key, values := range query {
+		for _, value := range values {
+			harReq.QueryString = append(harReq.QueryString, HARNameValue{key, value})
+		}
+	}
+	if len(body) > 0 {
+		harReq.PostData = &HARPostData{MimeType: req.Header.Get(headers.HeaderContentType)}
+		switch {
+		case info.sensitive.body || isText(body):
+			harReq.PostData.Text = info.sensitive.redactBody(req, body, len(body))
+		default:
+			harReq.PostData.Text = base64.StdEncoding.EncodeToString(body)
+			harReq.PostData.Encoding = "base64"
+		}
+	}
+	return harReq
+}
+
+// size is the whole size of body, which may be truncated
+func (creator Creator) newHARResponse(resp *http.Response, body []byte, size int64) HARResponse {
+	header := creator.redactHeader(resp.Header, nil, nil)
+	harResp := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     harHeaders(header),
+		Content: HARContent{
+			Size:     size,
+			MimeType: resp.Header.Get(headers.HeaderContentType),
+		},
+		RedirectURL: resp.Header.Get(headers.HeaderLocation),
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+	if size > int64(len(body)) {
+		harResp.Content.Comment = TruncatedMark
+	}
+	for _, cookie := range (&http.Response{Header: header}).Cookies() {
+		harResp.Cookies = append(harResp.Cookies, HARNameValue{cookie.Name, cookie.Value})
+	}
+	if isText(body) {
+		harResp.Content.Text = string(body)
+	} else {
+		harResp.Content.Text = base64.StdEncoding.EncodeToString(body)
+		harResp.Content.Encoding = "base64"
+	}
+	return harResp
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	pairs := make([]HARNameValue, 0, len(header))
+	for key, values := range header {
+		for _, value := range values {
+			pairs = append(pairs, HARNameValue{key, value})
+		}
+	}
+	return pairs
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+func (body *harBody) done(err error) {
+	body.once.Do(func() {
+		body.record(body.buf.Bytes(), body.size, err)
+	})
+}
+
+func (body *harBody) Read(p []byte) (n int, err error) {
+	n, err = body.ReadCloser.Read(p)
+	body.size += int64(n)
+	if rest := HARBodyLimit - body.buf.Len(); rest > 0 {
+		if rest > n {
+			rest = n
+		}
+		body.buf.Write(p[:rest])
+	}
+	switch {
+	case err == io.EOF:
+		body.done(nil)
+	case err != nil:
+		body.done(err)
+	}
+	return
+}
+
+func (body *harBody) Close() error {
+	body.done(nil)
+	return body.ReadCloser.Close()
+}
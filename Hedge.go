@@ -0,0 +1,165 @@
+package gotten
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+type (
+	// HedgeStats of a call of function with hedge delay
+	HedgeStats struct {
+		Delay    time.Duration // 0 if the call is not hedged
+		Attempts int           // 2 if a hedged request is sent
+		Winner   int           // index of the attempt responded, 1 is the hedged one
+	}
+
+	// HedgedResponse is implemented by Response of functions, see HedgeStatsOf
+	HedgedResponse interface {
+		Hedge() HedgeStats
+	}
+
+	hedgeStatsKey struct{}
+
+	hedgeResult struct {
+		index int
+		resp  *http.Response
+		err   error
+	}
+)
+
+// `hedge` tag of function covers the default delay, `hedge:"0"` disables it
+func processHedge(tag reflect.StructTag, defaultDelay time.Duration) (delay time.Duration, err error) {
+	delay = defaultDelay
+	if raw, ok := tag.Lookup(KeyHedge); ok {
+		if delay, err = time.ParseDuration(raw); err != nil || delay < 0 {
+			err = InvalidFuncTagError(KeyHedge, raw)
+		}
+	}
+	return
+}
+
+// a call is hedged only if method is idempotent and body can be replayed;
+// GetBody is set only if every attempt gets an independent body, so attempts never share a reader
+func hedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+	return false
+}
+
+func withHedgeStats(req *http.Request, stats *HedgeStats) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), hedgeStatsKey{}, stats))
+}
+
+// successful response has no error and status less than 500
+func (result hedgeResult) success() bool {
+	return result.err == nil && result.resp.StatusCode < http.StatusInternalServerError
+}
+
+// close body of the result not returned
+func (result hedgeResult) discard() {
+	if result.resp != nil {
+		result.resp.Body.Close()
+	}
+}
+
+// send the same request again if no response is got after delay, the first successful response is returned;
+// the other request is canceled, or the last failure is returned if both fail
+func withHedge(delay time.Duration, next doFunc) doFunc {
+	if delay <= 0 {
+		return next
+	}
+
+	return func(req *http.Request) (resp *http.Response, err error) {
+		if !hedgeable(req) {
+			return next(req)
+		}
+
+		stats, _ := req.Context().Value(hedgeStatsKey{}).(*HedgeStats)
+		if stats == nil {
+			stats = new(HedgeStats)
+		}
+		stats.Delay = delay
+
+		results := make(chan hedgeResult, 2)
+		cancels := make([]context.CancelFunc, 0, 2)
+		attempt := func(req *http.Request) {
+			ctx, cancel := context.WithCancel(req.Context())
+			cancels = append(cancels, cancel)
+			index := len(cancels) - 1
+			go func() {
+				resp, err := next(req.WithContext(ctx))
+				results <- hedgeResult{index, resp, err}
+			}()
+		}
+
+		stats.Attempts = 1
+		attempt(req)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		pending := 1
+		for {
+			select {
+			case <-timer.C:
+				hedged := req.Clone(req.Context())
+				if req.GetBody != nil {
+					if hedged.Body, err = req.GetBody(); err != nil {
+						// go on waiting for the first one
+						err = nil
+						continue
+					}
+				}
+				stats.Attempts = 2
+				pending++
+				attempt(hedged)
+			case result := <-results:
+				pending--
+				if !result.success() && pending > 0 {
+					cancels[result.index]()
+					result.discard()
+					continue
+				}
+
+				// cancel the other one
+				for index, cancel := range cancels {
+					if index != result.index {
+						cancel()
+					}
+				}
+				if pending > 0 {
+					go func() {
+						(<-results).discard()
+					}()
+				}
+
+				stats.Winner = result.index
+				if result.err != nil {
+					cancels[result.index]()
+					return nil, result.err
+				}
+				result.resp.Body = &cancelBody{result.resp.Body, cancels[result.index]}
+				return result.resp, nil
+			}
+		}
+	}
+}
+
+// HedgeStatsOf returns stats of hedged request, zero if the function has no hedge delay
+// or resp does not implement HedgedResponse
+func HedgeStatsOf(resp Response) (stats HedgeStats) {
+	if hedged, ok := resp.(HedgedResponse); ok {
+		stats = hedged.Hedge()
+	}
+	return
+}
+
+func (resp ResponseImpl) Hedge() (stats HedgeStats) {
+	if resp.hedge != nil {
+		stats = *resp.hedge
+	}
+	return
+}
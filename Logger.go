@@ -0,0 +1,256 @@
+package gotten
+
+import (
+	"bytes"
+	"github.com/Hexilee/gotten/headers"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LogMessage    = "gotten call"
+	RedactedValue = "[REDACTED]"
+	TruncatedMark = "...(truncated)"
+)
+
+var (
+	DefaultRedactedHeaders = []string{
+		headers.HeaderAuthorization,
+		headers.HeaderCookie,
+		headers.HeaderSetCookie,
+	}
+)
+
+type (
+	// keys of params fields tagged `sensitive:"true"`, by where they are sent
+	sensitiveKeys struct {
+		query  map[string]bool
+		header map[string]bool
+		cookie map[string]bool
+		form   map[string]bool
+		// json, xml or part in body
+		body bool
+	}
+
+	// reads peeked bytes first, then the rest of the original body
+	peekedBody struct {
+		io.Reader
+		closer io.Closer
+	}
+
+	// keeps at most limit+1 bytes read by caller, calls log once when it is read to EOF or closed
+	loggedBody struct {
+		io.ReadCloser
+		buf   bytes.Buffer
+		limit int
+		once  sync.Once
+		log   func(body []byte)
+	}
+)
+
+func newRedactedHeaders(keys []string) map[string]bool {
+	redacted := make(map[string]bool)
+	for _, key := range keys {
+		redacted[textproto.CanonicalMIMEHeaderKey(key)] = true
+	}
+	return redacted
+}
+
+// can only be called after parse
+func (parser *VarsParser) sensitiveKeys() *sensitiveKeys {
+	keys := &sensitiveKeys{
+		query:  make(map[string]bool),
+		header: make(map[string]bool),
+		cookie: make(map[string]bool),
+		form:   make(map[string]bool),
+	}
+	for _, field := range parser.fieldTable {
+		if field != nil && field.sensitive {
+			switch field.valueType {
+			case TypeQuery:
+				keys.query[field.key] = true
+			case TypeHeader:
+				keys.header[textproto.CanonicalMIMEHeaderKey(field.key)] = true
+			case TypeCookie:
+				keys.cookie[field.key] = true
+			case TypeForm:
+				keys.form[field.key] = true
+			case TypeMultipart:
+				keys.body = true
+				// path values are never logged
+			}
+		}
+	}
+	for _, field := range parser.ioFieldTable {
+		if field != nil && field.sensitive {
+			if parser.contentType == headers.MIMEApplicationForm {
+				keys.form[field.key] = true
+			} else {
+				keys.body = true
+			}
+		}
+	}
+	return keys
+}
+
+// a call is logged when the response arrives, or after its body is read to EOF or closed if bodies are logged
+func (creator Creator) withLogger(info *FuncInfo, next doFunc) doFunc {
+	if creator.logger == nil {
+		return next
+	}
+
+	return func(req *http.Request) (resp *http.Response, err error) {
+		var reqBody []byte
+		if creator.logBodyLimit > 0 && req.Body != nil {
+			reqBody, req.Body = peekBody(req.Body, creator.logBodyLimit)
+		}
+
+		start := time.Now()
+		resp, err = next(req)
+		attrs := []slog.Attr{
+			slog.String("service", info.Service),
+			slog.String("function", info.Name),
+			slog.String("method", info.Method),
+			slog.String("path", info.Path),
+			slog.String("query", info.sensitive.redactQuery(req.URL.Query())),
+			slog.Any("header", creator.redactHeader(req.Header, info.sensitive.header, info.sensitive.cookie)),
+			slog.Duration("duration", time.Since(start)),
+		}
+
+		if reqBody != nil {
+			attrs = append(attrs, slog.String("request_body", info.sensitive.redactBody(req, reqBody, creator.logBodyLimit)))
+		}
+
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", err.Error()))
+		} else {
+			if resp.StatusCode >= http.StatusInternalServerError {
+				level = slog.LevelWarn
+			}
+			attrs = append(attrs,
+				slog.Int("status", resp.StatusCode),
+				slog.Any("response_header", creator.redactHeader(resp.Header, nil, nil)),
+			)
+			if creator.logBodyLimit > 0 && resp.Body != nil {
+				// response body is logged after caller reads it, never block on a streaming body
+				ctx := req.Context()
+				resp.Body = &loggedBody{ReadCloser: resp.Body, limit: creator.logBodyLimit, log: func(body []byte) {
+					attrs = append(attrs, slog.String("response_body", truncate(body, creator.logBodyLimit)))
+					creator.logger.LogAttrs(ctx, level, LogMessage, attrs...)
+				}}
+				return
+			}
+		}
+		creator.logger.LogAttrs(req.Context(), level, LogMessage, attrs...)
+		return
+	}
+}
+
+// returns a copy of header with values of redacted and sensitive keys masked
+func (creator Creator) redactHeader(header http.Header, sensitive, sensitiveCookies map[string]bool) http.Header {
+	result := make(http.Header)
+	for key, values := range header {
+		switch {
+		case creator.redacted[key] || sensitive[key]:
+			result[key] = []string{RedactedValue}
+		case key == headers.HeaderCookie && len(sensitiveCookies) > 0:
+			result[key] = []string{redactCookies(values, sensitiveCookies)}
+		default:
+			result[key] = values
+		}
+	}
+	return result
+}
+
+func redactCookies(values []string, sensitive map[string]bool) string {
+	request := http.Request{Header: http.Header{headers.HeaderCookie: values}}
+	pairs := make([]string, 0)
+	for _, cookie := range request.Cookies() {
+		value := cookie.Value
+		if sensitive[cookie.Name] {
+			value = RedactedValue
+		}
+		pairs = append(pairs, cookie.Name+"="+value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+func (keys sensitiveKeys) redactQuery(query url.Values) string {
+	return redactValues(query, keys.query)
+}
+
+func (keys sensitiveKeys) redactBody(req *http.Request, body []byte, limit int) string {
+	if keys.body {
+		return RedactedValue
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(headers.HeaderContentType))
+	if mediaType == headers.MIMEApplicationForm && len(keys.form) > 0 {
+		// truncated form may lose the tail value, never mind
+		form, _ := url.ParseQuery(string(body))
+		body = []byte(redactValues(form, keys.form))
+	}
+	return truncate(body, limit)
+}
+
+func redactValues(values url.Values, sensitive map[string]bool) string {
+	for key := range sensitive {
+		if _, ok := values[key]; ok {
+			values[key] = []string{RedactedValue}
+		}
+	}
+	return values.Encode()
+}
+
+// peek at most limit+1 bytes, to know whether the body is truncated
+func peekBody(body io.ReadCloser, limit int) (peeked []byte, restored io.ReadCloser) {
+	peeked, _ = ioutil.ReadAll(io.LimitReader(body, int64(limit)+1))
+	restored = &peekedBody{io.MultiReader(bytes.NewReader(peeked), body), body}
+	return
+}
+
+func truncate(data []byte, limit int) string {
+	if len(data) > limit {
+		return string(data[:limit]) + TruncatedMark
+	}
+	return string(data)
+}
+
+func (body *peekedBody) Close() error {
+	return body.closer.Close()
+}
+
+func (body *loggedBody) done() {
+	body.once.Do(func() {
+		body.log(body.buf.Bytes())
+	})
+}
+
+func (body *loggedBody) Read(p []byte) (n int, err error) {
+	n, err = body.ReadCloser.Read(p)
+	if rest := body.limit + 1 - body.buf.Len(); rest > 0 {
+		if rest > n {
+			rest = n
+		}
+		body.buf.Write(p[:rest])
+	}
+	if err == io.EOF {
+		body.done()
+	}
+	return
+}
+
+func (body *loggedBody) Close() error {
+	body.done()
+	return body.ReadCloser.Close()
+}
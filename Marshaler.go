@@ -0,0 +1,75 @@
+package gotten
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/Hexilee/gotten/headers"
+	"reflect"
+)
+
+type (
+	MarshalFunc func(v interface{}) ([]byte, error)
+
+	// BodyMarshaler encodes params fields of a value type into request body
+	BodyMarshaler struct {
+		contentType string
+		// of the part in multipart body, contentType if it is empty
+		partContentType string
+		// can only be called by parse
+		getReaderGetterFunc func(fieldType reflect.Type, valueType string) (func(value reflect.Value) (Reader, error), error)
+	}
+
+	// key: value type, like TypeJSON
+	BodyMarshalers map[string]*BodyMarshaler
+)
+
+func NewBodyMarshaler(contentType string, marshalFunc MarshalFunc) *BodyMarshaler {
+	return &BodyMarshaler{
+		contentType:         contentType,
+		getReaderGetterFunc: getMarshalerReaderGetterFunc(marshalFunc),
+	}
+}
+
+func (marshaler BodyMarshaler) ContentType() string {
+	return marshaler.contentType
+}
+
+func (marshaler BodyMarshaler) PartContentType() string {
+	if marshaler.partContentType == ZeroStr {
+		return marshaler.contentType
+	}
+	return marshaler.partContentType
+}
+
+// TypeJSON, TypeXML and TypeProtobuf
+func DefaultMarshalers() BodyMarshalers {
+	return BodyMarshalers{
+		TypeJSON: {
+			contentType: headers.MIMEApplicationJSONCharsetUTF8,
+			// json parts have been sent as javascript
+			partContentType:     headers.MIMEApplicationJavaScriptCharsetUTF8,
+			getReaderGetterFunc: getMarshalerReaderGetterFunc(json.Marshal),
+		},
+		TypeXML: NewBodyMarshaler(headers.MIMEApplicationXMLCharsetUTF8, xml.Marshal),
+		TypeProtobuf: {
+			contentType:         headers.MIMEApplicationXProtobuf,
+			getReaderGetterFunc: getProtobufReaderGetterFunc,
+		},
+	}
+}
+
+// value types handled by VarsParser itself, cannot be registered
+func reservedValueType(valueType string) bool {
+	switch valueType {
+	case TypePath, TypeQuery, TypeHeader, TypeCookie, TypeForm, TypeMultipart, ZeroStr:
+		return true
+	}
+	return false
+}
+
+// content types decided by VarsParser itself, cannot be registered
+func reservedContentType(contentType string) bool {
+	return contentType == ZeroStr ||
+		contentType == headers.MIMEApplicationForm ||
+		contentType == headers.MIMEMultipartForm
+}
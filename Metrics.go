@@ -0,0 +1,134 @@
+package gotten
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// status class of requests failed without response
+	StatusClassError = "error"
+)
+
+type (
+	// labels of every metric recorded by Creator;
+	// Path is the path template from tag, never the raw url
+	MetricLabels struct {
+		Service  string
+		Function string
+		Method   string
+		Path     string
+	}
+
+	Metrics interface {
+		// delta is 1 when a request is sent, -1 when the response (or error) is got
+		InFlight(labels MetricLabels, delta float64)
+
+		// status is the class of status code, like "2xx", or StatusClassError;
+		// size is negative if the request size is unknown
+		ObserveRequest(labels MetricLabels, status string, latency time.Duration, size int64)
+
+		// called once the size of response body is known
+		ObserveResponseSize(labels MetricLabels, status string, size int64)
+	}
+
+	// counts bytes read from body, report on EOF or Close
+	sizeObserverBody struct {
+		io.ReadCloser
+		metrics  Metrics
+		labels   MetricLabels
+		status   string
+		size     int64
+		observed bool
+	}
+)
+
+func (creator Creator) withMetrics(info *FuncInfo, next doFunc) doFunc {
+	if creator.metrics == nil {
+		return next
+	}
+
+	labels := info.labels()
+	return func(req *http.Request) (resp *http.Response, err error) {
+		creator.metrics.InFlight(labels, 1)
+		start := time.Now()
+		resp, err = next(req)
+		creator.metrics.InFlight(labels, -1)
+		status := StatusClass(resp, err)
+		creator.metrics.ObserveRequest(labels, status, time.Since(start), requestSize(req))
+		if err == nil {
+			observeResponseSize(creator.metrics, labels, status, resp)
+		}
+		return
+	}
+}
+
+func (info FuncInfo) labels() MetricLabels {
+	return MetricLabels{
+		Service:  info.Service,
+		Function: info.Name,
+		Method:   info.Method,
+		Path:     info.Path,
+	}
+}
+
+// StatusClass returns "1xx" ~ "5xx", or StatusClassError if err is not nil
+func StatusClass(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return StatusClassError
+	}
+	return strconv.Itoa(resp.StatusCode/100) + "xx"
+}
+
+// -1 means unknown
+func requestSize(req *http.Request) int64 {
+	switch {
+	case req.ContentLength > 0:
+		return req.ContentLength
+	case req.Body == nil || req.Body == http.NoBody:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// observe now if Content-Length is known, otherwise count the body
+func observeResponseSize(metrics Metrics, labels MetricLabels, status string, resp *http.Response) {
+	if resp.ContentLength >= 0 || resp.Body == nil {
+		size := resp.ContentLength
+		if size < 0 {
+			size = 0
+		}
+		metrics.ObserveResponseSize(labels, status, size)
+	} else {
+		resp.Body = &sizeObserverBody{
+			ReadCloser: resp.Body,
+			metrics:    metrics,
+			labels:     labels,
+			status:     status,
+		}
+	}
+}
+
+func (body *sizeObserverBody) Read(p []byte) (n int, err error) {
+	n, err = body.ReadCloser.Read(p)
+	body.size += int64(n)
+	if err == io.EOF {
+		body.observe()
+	}
+	return
+}
+
+func (body *sizeObserverBody) Close() error {
+	body.observe()
+	return body.ReadCloser.Close()
+}
+
+func (body *sizeObserverBody) observe() {
+	if !body.observed {
+		body.observed = true
+		body.metrics.ObserveResponseSize(body.labels, body.status, body.size)
+	}
+}
@@ -0,0 +1,120 @@
+package gotten
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"reflect"
+)
+
+type (
+	// FilePart is a file part of multipart, with filename, content type and custom headers;
+	// Reader is written like a field of io.Reader
+	FilePart struct {
+		Name        string // filename, omitted if empty
+		ContentType string // Default: application/octet-stream
+		Reader      io.Reader
+		Header      http.Header
+	}
+
+	// FSFile is a file in FS, opened when it is written, like FilePath
+	FSFile struct {
+		FS   fs.FS
+		Path string
+	}
+
+	// partAdder adds a part to multipartParts when body is got
+	partAdder func(parts *multipartParts) error
+)
+
+var (
+	FilePartType     = reflect.TypeOf(FilePart{})
+	FilePartPtrType  = reflect.TypeOf(&FilePart{})
+	FilePartsType    = reflect.TypeOf([]FilePart{})
+	FilePartPtrsType = reflect.TypeOf([]*FilePart{})
+	FilePathsType    = reflect.TypeOf([]FilePath{})
+	FSFileType       = reflect.TypeOf(FSFile{})
+	FSFilesType      = reflect.TypeOf([]FSFile{})
+)
+
+// field types of TypeMultipart which may be zero or more file parts
+func isFilePartType(fieldType reflect.Type) bool {
+	switch fieldType {
+	case FilePartType, FilePartPtrType, FilePartsType, FilePartPtrsType, FilePathsType, FSFileType, FSFilesType:
+		return true
+	}
+	return false
+}
+
+// empty parts are skipped; closers are closable readers of parts
+func getFilePartAdders(key string, value reflect.Value) (adders []partAdder, closers []io.Closer) {
+	addPart := func(part *FilePart) {
+		if part != nil && part.Reader != nil {
+			if closer, ok := unwrapReader(part.Reader).(io.Closer); ok {
+				closers = append(closers, closer)
+			}
+			adders = append(adders, func(parts *multipartParts) error {
+				return parts.addFilePart(key, part)
+			})
+		}
+	}
+
+	addPath := func(path FilePath) {
+		if path != ZeroStr {
+			adders = append(adders, func(parts *multipartParts) error {
+				return parts.addFile(key, string(path))
+			})
+		}
+	}
+
+	addFSFile := func(file FSFile) {
+		if file.FS != nil {
+			adders = append(adders, func(parts *multipartParts) error {
+				return parts.addFSFile(key, file)
+			})
+		}
+	}
+
+	switch val := value.Interface().(type) {
+	case FilePart:
+		addPart(&val)
+	case *FilePart:
+		addPart(val)
+	case []FilePart:
+		for i := range val {
+			addPart(&val[i])
+		}
+	case []*FilePart:
+		for _, part := range val {
+			addPart(part)
+		}
+	case []FilePath:
+		for _, path := range val {
+			addPath(path)
+		}
+	case FSFile:
+		addFSFile(val)
+	case []FSFile:
+		for _, file := range val {
+			addFSFile(file)
+		}
+	}
+	return
+}
+
+// file is opened when it is written, and closed after that
+func (parts *multipartParts) addFSFile(key string, file FSFile) (err error) {
+	var info fs.FileInfo
+	if info, err = fs.Stat(file.FS, file.Path); err == nil {
+		parts.parts = append(parts.parts, &multipartPart{
+			header:     fileHeader(key, path.Base(file.Path), ZeroStr, nil),
+			size:       info.Size(),
+			rewindable: true,
+			open: func() (io.Reader, error) {
+				return file.FS.Open(file.Path)
+			},
+		})
+	}
+	return
+}
@@ -0,0 +1,99 @@
+package gotten
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+type (
+	// ProgressEvent is reported on each read of request or response body
+	ProgressEvent struct {
+		Request     *http.Request
+		Direction   string // DirectionUpload or DirectionDownload
+		Transferred int64
+		Total       int64 // Content-Length, UnknownLength if unknown
+		Done        bool
+	}
+
+	// Progress can be set by Builder.SetProgress, or a params field of type Progress for a call;
+	// reports of download end when Response.Unmarshal or FileCtr.Unmarshal consumes the body
+	Progress func(event ProgressEvent)
+
+	progressKey struct{}
+
+	progressBody struct {
+		io.ReadCloser
+		progress Progress
+		event    ProgressEvent
+	}
+)
+
+var (
+	ProgressType = reflect.TypeOf(Progress(nil))
+)
+
+func withProgressContext(req *http.Request, progress Progress) *http.Request {
+	if progress == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), progressKey{}, progress))
+}
+
+// progress of call covers progress of creator
+func (creator Creator) withProgress(do doFunc) doFunc {
+	return func(req *http.Request) (resp *http.Response, err error) {
+		progress, _ := req.Context().Value(progressKey{}).(Progress)
+		if progress == nil {
+			progress = creator.progress
+		}
+
+		if progress == nil {
+			return do(req)
+		}
+
+		if req.Body != nil && req.Body != http.NoBody {
+			req.Body = newProgressBody(req, req.Body, DirectionUpload, req.ContentLength, progress)
+			if getBody := req.GetBody; getBody != nil {
+				req.GetBody = func() (body io.ReadCloser, err error) {
+					if body, err = getBody(); err == nil {
+						body = newProgressBody(req, body, DirectionUpload, req.ContentLength, progress)
+					}
+					return
+				}
+			}
+		}
+
+		if resp, err = do(req); err == nil {
+			resp.Body = newProgressBody(req, resp.Body, DirectionDownload, resp.ContentLength, progress)
+		}
+		return
+	}
+}
+
+func newProgressBody(req *http.Request, body io.ReadCloser, direction string, total int64, progress Progress) *progressBody {
+	if total <= 0 {
+		total = UnknownLength
+	}
+	return &progressBody{
+		ReadCloser: body,
+		progress:   progress,
+		event:      ProgressEvent{Request: req, Direction: direction, Total: total},
+	}
+}
+
+func (body *progressBody) Read(p []byte) (n int, err error) {
+	n, err = body.ReadCloser.Read(p)
+	if !body.event.Done && (n > 0 || err == io.EOF) {
+		body.event.Transferred += int64(n)
+		body.event.Done = err == io.EOF || body.event.Transferred == body.event.Total
+		body.progress(body.event)
+	}
+	return
+}
@@ -0,0 +1,346 @@
+package gotten
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Hexilee/gotten/headers"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// separator of count and period in `ratelimit` tag, like `ratelimit:"10/s"` or `ratelimit:"3/500ms"`
+	RateSeparator = "/"
+
+	// X-RateLimit-Reset larger than it is a unix time, otherwise it is seconds to wait
+	rateLimitResetEpoch = 1e9
+)
+
+// scopes of limits
+const (
+	ScopeCreator  = "creator"
+	ScopeHost     = "host"
+	ScopeFunction = "function"
+)
+
+type (
+	// LimitError is returned instead of waiting if Builder.SetLimitFailFast is set
+	LimitError struct {
+		Scope       string        // ScopeCreator, ScopeHost or ScopeFunction
+		Key         string        // host, or "Service.Function"
+		Concurrency bool          // false if rate limit is exceeded
+		RetryAfter  time.Duration // until the next token; 0 if concurrency limit is exceeded
+	}
+
+	// count per period and max concurrency, 0 means unlimited
+	limitSpec struct {
+		count       int
+		per         time.Duration
+		concurrency int
+	}
+
+	// token bucket of burst count, refilled at rate; and semaphore of max concurrency
+	limiter struct {
+		scope string
+		key   string
+		rate  float64       // tokens per second, unlimited if 0
+		burst float64       // count of limitSpec
+		slots chan struct{} // nil if concurrency is unlimited
+
+		mutex       sync.Mutex
+		tokens      float64 // negative if some callers are waiting
+		last        time.Time
+		pausedUntil time.Time // set by Retry-After or X-RateLimit-Reset
+	}
+
+	// limits of a Creator, checked for every request sent
+	limits struct {
+		failFast bool
+		adaptive bool
+		creator  *limiter // nil if unlimited
+		specs    map[string]limitSpec
+
+		mutex sync.Mutex
+		hosts map[string]*limiter // created on the first request to host
+	}
+
+	// release limiters when body is closed or read to EOF
+	releaseBody struct {
+		io.ReadCloser
+		once    sync.Once
+		release func()
+	}
+)
+
+func (err *LimitError) Error() string {
+	message := RateLimitExceeded
+	if err.Concurrency {
+		message = ConcurrencyLimitExceeded
+	}
+	message += fmt.Sprintf(": %s(%s)", err.Scope, err.Key)
+	if err.RetryAfter > 0 {
+		message += fmt.Sprintf(", retry after %s", err.RetryAfter)
+	}
+	return message
+}
+
+// ParseRate parses rate like "10/s", "100/m", "1000/h" or "3/500ms"
+func ParseRate(raw string) (count int, per time.Duration, err error) {
+	parts := strings.Split(raw, RateSeparator)
+	if len(parts) != 2 {
+		err = errors.New(InvalidRate + ": " + raw)
+		return
+	}
+	if count, err = strconv.Atoi(strings.TrimSpace(parts[0])); err == nil && count > 0 {
+		switch unit := strings.TrimSpace(parts[1]); unit {
+		case "s", "m", "h":
+			per, err = time.ParseDuration("1" + unit)
+		default:
+			per, err = time.ParseDuration(unit)
+		}
+	}
+	if err != nil || count <= 0 || per <= 0 {
+		err = errors.New(InvalidRate + ": " + raw)
+	}
+	return
+}
+
+// nil if spec is unlimited and not adaptive
+func newLimiter(scope, key string, spec limitSpec, adaptive bool) (lim *limiter) {
+	limited := spec.count > 0 && spec.per > 0
+	if !limited && spec.concurrency <= 0 && !adaptive {
+		return
+	}
+	lim = &limiter{scope: scope, key: key, last: time.Now()}
+	if limited {
+		lim.burst = float64(spec.count)
+		lim.rate = lim.burst / spec.per.Seconds()
+		lim.tokens = lim.burst
+	}
+	if spec.concurrency > 0 {
+		lim.slots = make(chan struct{}, spec.concurrency)
+	}
+	return
+}
+
+// `ratelimit` and `concurrency` tags of function; nil if neither is set
+func processLimit(tag reflect.StructTag, info *FuncInfo) (lim *limiter, err error) {
+	var spec limitSpec
+	if raw, ok := tag.Lookup(KeyRateLimit); ok {
+		if spec.count, spec.per, err = ParseRate(raw); err != nil {
+			err = InvalidFuncTagError(KeyRateLimit, raw)
+			return
+		}
+	}
+	if raw, ok := tag.Lookup(KeyConcurrency); ok {
+		if spec.concurrency, err = strconv.Atoi(raw); err != nil || spec.concurrency <= 0 {
+			err = InvalidFuncTagError(KeyConcurrency, raw)
+			return
+		}
+	}
+	lim = newLimiter(ScopeFunction, info.Service+"."+info.Name, spec, false)
+	return
+}
+
+// take a slot and a token, wait for them unless failFast is set
+func (lim *limiter) acquire(ctx context.Context, failFast bool) (release func(), err error) {
+	release = func() {}
+	if lim.slots != nil {
+		if failFast {
+			select {
+			case lim.slots <- struct{}{}:
+			default:
+				err = &LimitError{Scope: lim.scope, Key: lim.key, Concurrency: true}
+				return
+			}
+		} else {
+			select {
+			case lim.slots <- struct{}{}:
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			}
+		}
+		release = func() { <-lim.slots }
+	}
+
+	if wait := lim.reserve(failFast); wait > 0 {
+		if failFast {
+			err = &LimitError{Scope: lim.scope, Key: lim.key, RetryAfter: wait}
+		} else {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				lim.refund()
+				err = ctx.Err()
+			}
+		}
+		if err != nil {
+			release()
+		}
+	}
+	return
+}
+
+// take a token and return time to wait for it; nothing is taken if failFast is set and it has to wait
+func (lim *limiter) reserve(failFast bool) (wait time.Duration) {
+	lim.mutex.Lock()
+	defer lim.mutex.Unlock()
+	now := time.Now()
+	if now.Before(lim.pausedUntil) {
+		wait = lim.pausedUntil.Sub(now)
+	}
+	if lim.rate > 0 {
+		lim.tokens = math.Min(lim.burst, lim.tokens+now.Sub(lim.last).Seconds()*lim.rate)
+		lim.last = now
+		if lim.tokens < 1 {
+			if tokenWait := time.Duration((1 - lim.tokens) / lim.rate * float64(time.Second)); tokenWait > wait {
+				wait = tokenWait
+			}
+		}
+		if failFast && wait > 0 {
+			return
+		}
+		lim.tokens--
+	}
+	return
+}
+
+func (lim *limiter) refund() {
+	lim.mutex.Lock()
+	defer lim.mutex.Unlock()
+	if lim.rate > 0 {
+		lim.tokens++
+	}
+}
+
+// pause until Retry-After, or until X-RateLimit-Reset if X-RateLimit-Remaining is 0;
+// tokens are cut to X-RateLimit-Remaining
+func (lim *limiter) adapt(resp *http.Response) {
+	lim.mutex.Lock()
+	defer lim.mutex.Unlock()
+	now := time.Now()
+	pause := func(until time.Time) {
+		if until.After(lim.pausedUntil) {
+			lim.pausedUntil = until
+		}
+	}
+
+	if raw := resp.Header.Get(headers.HeaderRetryAfter); raw != ZeroStr {
+		if seconds, err := strconv.Atoi(raw); err == nil {
+			pause(now.Add(time.Duration(seconds) * time.Second))
+		} else if date, err := http.ParseTime(raw); err == nil {
+			pause(date)
+		}
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get(headers.HeaderXRateLimitRemaining))
+	if err != nil {
+		return
+	}
+	if lim.rate > 0 && float64(remaining) < lim.tokens {
+		lim.tokens = float64(remaining)
+	}
+	if remaining <= 0 {
+		if reset, err := strconv.ParseInt(resp.Header.Get(headers.HeaderXRateLimitReset), 10, 64); err == nil {
+			if reset > rateLimitResetEpoch {
+				pause(time.Unix(reset, 0))
+			} else {
+				pause(now.Add(time.Duration(reset) * time.Second))
+			}
+		}
+	}
+}
+
+func newLimits(spec limitSpec, specs map[string]limitSpec, failFast, adaptive bool) *limits {
+	return &limits{
+		failFast: failFast,
+		adaptive: adaptive,
+		creator:  newLimiter(ScopeCreator, ScopeCreator, spec, false),
+		specs:    specs,
+		hosts:    make(map[string]*limiter),
+	}
+}
+
+// limiter of host, nil if host is unlimited and limits are not adaptive
+func (l *limits) host(host string) *limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	lim, ok := l.hosts[host]
+	if !ok {
+		lim = newLimiter(ScopeHost, host, l.specs[host], l.adaptive)
+		l.hosts[host] = lim
+	}
+	return lim
+}
+
+// acquire limiters of function, host and creator in order before request is sent,
+// release them when response body is closed; tokens taken are given back if any of them fails
+func (l *limits) withLimits(info *FuncInfo, next doFunc) doFunc {
+	if l.creator == nil && len(l.specs) == 0 && !l.adaptive && info.limiter == nil {
+		return next
+	}
+
+	return func(req *http.Request) (resp *http.Response, err error) {
+		host := l.host(req.URL.Host)
+		var acquired []*limiter
+		var releases []func()
+		release := func() {
+			for _, done := range releases {
+				done()
+			}
+		}
+
+		for _, lim := range []*limiter{info.limiter, host, l.creator} {
+			if lim == nil {
+				continue
+			}
+			var done func()
+			if done, err = lim.acquire(req.Context(), l.failFast); err != nil {
+				// the request is never sent
+				for _, taken := range acquired {
+					taken.refund()
+				}
+				release()
+				return
+			}
+			acquired = append(acquired, lim)
+			releases = append(releases, done)
+		}
+
+		if resp, err = next(req); err != nil {
+			release()
+			return
+		}
+		if l.adaptive {
+			host.adapt(resp)
+		}
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+		return
+	}
+}
+
+func (body *releaseBody) done() {
+	body.once.Do(body.release)
+}
+
+func (body *releaseBody) Read(p []byte) (n int, err error) {
+	if n, err = body.ReadCloser.Read(p); err == io.EOF {
+		body.done()
+	}
+	return
+}
+
+func (body *releaseBody) Close() error {
+	body.done()
+	return body.ReadCloser.Close()
+}
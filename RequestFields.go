@@ -2,9 +2,8 @@ package gotten
 
 import (
 	"bytes"
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
+	"google.golang.org/protobuf/proto"
 	"io"
 	"reflect"
 	"strconv"
@@ -27,7 +26,9 @@ const (
 	// support types: fmt.Stringer, int, string
 	TypeCookie = "cookie"
 
-	// support types: fmt.Stringer, int, string, Reader, FilePath
+	// support types: fmt.Stringer, int, string, Reader, FilePath,
+	// and file parts: []FilePath, FilePart, *FilePart, []FilePart, []*FilePart, FSFile, []FSFile;
+	// parts are written in order of fields, or sorted by `order:"n"` (Default: 0)
 	TypeMultipart = "part"
 
 	// support types: fmt.Stringer, Reader, string, struct, slice, map
@@ -35,6 +36,12 @@ const (
 
 	// support types: fmt.Stringer, Reader, string, struct, slice, map
 	TypeXML = "xml"
+
+	// support types: fmt.Stringer, Reader, string, proto.Message
+	TypeProtobuf = "protobuf"
+
+	// more body types can be registered by Builder.RegisterMarshaler,
+	// support types: fmt.Stringer, Reader, string, struct, slice, map
 )
 
 type (
@@ -47,6 +54,8 @@ var (
 	FilePathType = reflect.TypeOf(filePath)
 	IntType      = reflect.TypeOf(int(1))
 	StringType   = reflect.TypeOf("")
+
+	ProtoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
 )
 
 func getMultipartValueGetterFunc(fieldType reflect.Type, valueType string) (getValueFunc func(value reflect.Value) (string, error), err error) {
@@ -95,38 +104,40 @@ func getReaderGetterFunc(fieldType reflect.Type, valueType string) (getValueFunc
 	return
 }
 
+// for body marshalers; field of ptr, struct, slice or map is marshaled by marshalFunc
+func getMarshalerReaderGetterFunc(marshalFunc MarshalFunc) func(fieldType reflect.Type, valueType string) (func(value reflect.Value) (Reader, error), error) {
+	return func(fieldType reflect.Type, valueType string) (getValueFunc func(value reflect.Value) (Reader, error), err error) {
+		fieldKind := fieldType.Kind()
+		switch fieldKind {
+		case reflect.Ptr:
+			fallthrough
+		case reflect.Struct:
+			fallthrough
+		case reflect.Slice:
+			fallthrough
+		case reflect.Map:
+			getValueFunc = getMarshalReaderGetterFunc(marshalFunc)
+		default:
+			getValueFunc, err = getReaderGetterFunc(fieldType, valueType)
+		}
+		return
+	}
+}
+
 // can only be called by parse
-func getJSONReaderGetterFunc(fieldType reflect.Type, valueType string) (getValueFunc func(value reflect.Value) (Reader, error), err error) {
+func getProtobufReaderGetterFunc(fieldType reflect.Type, valueType string) (getValueFunc func(value reflect.Value) (Reader, error), err error) {
 	fieldKind := fieldType.Kind()
-	switch fieldKind {
-	case reflect.Ptr:
-		fallthrough
-	case reflect.Struct:
-		fallthrough
-	case reflect.Slice:
-		fallthrough
-	case reflect.Map:
-		getValueFunc = getMarshalReaderGetterFunc(json.Marshal)
-	default:
+	if (fieldKind == reflect.Ptr || fieldKind == reflect.Interface) && fieldType.Implements(ProtoMessageType) {
+		getValueFunc = getMarshalReaderGetterFunc(marshalProtobuf)
+	} else {
 		getValueFunc, err = getReaderGetterFunc(fieldType, valueType)
 	}
 	return
 }
 
-// can only be called by parse
-func getXMLReaderGetterFunc(fieldType reflect.Type, valueType string) (getValueFunc func(value reflect.Value) (Reader, error), err error) {
-	fieldKind := fieldType.Kind()
-	switch fieldKind {
-	case reflect.Ptr:
-		fallthrough
-	case reflect.Struct:
-		fallthrough
-	case reflect.Slice:
-		fallthrough
-	case reflect.Map:
-		getValueFunc = getMarshalReaderGetterFunc(xml.Marshal)
-	default:
-		getValueFunc, err = getReaderGetterFunc(fieldType, valueType)
+func marshalProtobuf(obj interface{}) (data []byte, err error) {
+	if message, ok := obj.(proto.Message); ok {
+		data, err = proto.Marshal(message)
 	}
 	return
 }
@@ -134,8 +145,17 @@ func getXMLReaderGetterFunc(fieldType reflect.Type, valueType string) (getValueF
 func getMarshalReaderGetterFunc(marshalFunc func(obj interface{}) ([]byte, error)) func(value reflect.Value) (Reader, error) {
 	return func(value reflect.Value) (Reader, error) {
 		data, err := marshalFunc(value.Interface())
-		return newReadCloser(bytes.NewBuffer(data), value.IsNil()), err
+		return newReadCloser(bytes.NewBuffer(data), isNil(value)), err
+	}
+}
+
+// struct is never nil
+func isNil(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return value.IsNil()
 	}
+	return false
 }
 
 func getValueFromStringer(value reflect.Value) (str string, err error) {
@@ -32,11 +32,12 @@ type (
 	ResponseImpl struct {
 		*http.Response
 		unmarshaler ReadUnmarshaler
+		hedge       *HedgeStats
 	}
 )
 
-func newResponse(resp *http.Response, unmarshaler ReadUnmarshaler) Response {
-	return &ResponseImpl{resp, unmarshaler}
+func newResponse(resp *http.Response, unmarshaler ReadUnmarshaler, hedge *HedgeStats) Response {
+	return &ResponseImpl{resp, unmarshaler, hedge}
 }
 
 func (resp ResponseImpl) StatusCode() int {
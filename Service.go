@@ -0,0 +1,120 @@
+package gotten
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// separator of headers in `headers` tag, like `headers:"X-Api-Version: 2\nAccept: application/json; charset=utf-8"`;
+	// it never appears in a header value, unlike ";" of media type parameters
+	HeaderSeparator = "\n"
+)
+
+type (
+	// Service is embedded in a service struct, its tags apply to every function of the struct:
+	// `base:"/v2"` is prefixed to path, `headers:"X-Api-Version: 2"` covers headers of Creator,
+	// `timeout:"5s"` limits functions returning Response, including reading the body
+	Service struct{}
+
+	// config of embedded Service
+	serviceConfig struct {
+		base    string
+		headers http.Header
+		timeout time.Duration
+	}
+
+	// cancel context of timeout when body is closed
+	cancelBody struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+)
+
+var (
+	ServiceType = reflect.TypeOf(Service{})
+)
+
+// config of the embedded Service, empty config if absent
+func newServiceConfig(serviceType reflect.Type) (config *serviceConfig, err error) {
+	config = &serviceConfig{headers: make(http.Header)}
+	for i := 0; i < serviceType.NumField(); i++ {
+		if field := serviceType.Field(i); isServiceMarker(field) {
+			config.base = field.Tag.Get(KeyBase)
+			if config.headers, err = parseHeaders(field.Tag.Get(KeyHeaders)); err == nil {
+				config.timeout, err = processTimeout(field.Tag.Get(KeyTimeout))
+			}
+			break
+		}
+	}
+	return
+}
+
+func isServiceMarker(field reflect.StructField) bool {
+	return field.Anonymous && field.Type == ServiceType
+}
+
+// base is prefixed to path of function
+func (config *serviceConfig) path(path string) string {
+	if config.base == ZeroStr {
+		return path
+	}
+	if path == ZeroStr {
+		return config.base
+	}
+	return strings.TrimRight(config.base, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// "Key: value\nKey: value"
+func parseHeaders(raw string) (header http.Header, err error) {
+	header = make(http.Header)
+	for _, line := range strings.Split(raw, HeaderSeparator) {
+		if line = strings.TrimSpace(line); line == ZeroStr {
+			continue
+		}
+
+		pair := strings.SplitN(line, ":", 2)
+		key := strings.TrimSpace(pair[0])
+		if len(pair) != 2 || key == ZeroStr || strings.ContainsAny(key, " \t") {
+			return nil, InvalidServiceTagError(KeyHeaders, raw)
+		}
+		header.Add(textproto.CanonicalMIMEHeaderKey(key), strings.TrimSpace(pair[1]))
+	}
+	return
+}
+
+func processTimeout(raw string) (timeout time.Duration, err error) {
+	if raw != ZeroStr {
+		if timeout, err = time.ParseDuration(raw); err != nil || timeout < 0 {
+			err = InvalidServiceTagError(KeyTimeout, raw)
+		}
+	}
+	return
+}
+
+// the context of request is canceled after timeout, or when body of response is closed
+func withTimeout(timeout time.Duration, next doFunc) doFunc {
+	if timeout == 0 {
+		return next
+	}
+	return func(req *http.Request) (resp *http.Response, err error) {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		if resp, err = next(req.WithContext(ctx)); err != nil {
+			cancel()
+			return
+		}
+		resp.Body = &cancelBody{resp.Body, cancel}
+		return
+	}
+}
+
+func (body *cancelBody) Close() (err error) {
+	err = body.ReadCloser.Close()
+	body.cancel()
+	return
+}
@@ -8,4 +8,39 @@ const (
 	KeyPath    = "path"
 	KeyDefault = "default"
 	KeyRequire = "require"
+
+	// Accept header of a service function, overriding the generated one
+	KeyAccept = "accept"
+
+	// order of a multipart part, parts are in order of fields by default
+	KeyOrder = "order"
+
+	// validation rules, checked before request is sent; only fields of string, int, fmt.Stringer, slice, array or map
+	// can carry them, Creator.Impl returns UnsupportedValidationTypeError for others, like structs of json bodies
+	KeyMin     = "min"
+	KeyMax     = "max"
+	KeyLen     = "len"
+	KeyPattern = "pattern"
+	KeyEnum    = "enum"
+	KeyFormat  = "format"
+
+	// tags of embedded Service
+	KeyBase    = "base"
+	KeyHeaders = "headers"
+	KeyTimeout = "timeout"
+
+	// delay of hedged request of a function, like `hedge:"50ms"`
+	KeyHedge = "hedge"
+
+	// identical GET calls of a function in flight are coalesced, like `coalesce:"Authorization"`
+	KeyCoalesce = "coalesce"
+
+	// rate limit of a function, like `ratelimit:"10/s"`
+	KeyRateLimit = "ratelimit"
+
+	// max concurrent calls of a function, like `concurrency:"4"`
+	KeyConcurrency = "concurrency"
+
+	// masked in logs
+	KeySensitive = "sensitive"
 )
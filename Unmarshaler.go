@@ -1,9 +1,11 @@
 package gotten
 
 import (
+	"google.golang.org/protobuf/proto"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 )
 
 type (
@@ -36,6 +38,19 @@ func NewReaderAdapter(unmarshaler Unmarshaler) ReadUnmarshaler {
 	return &ReaderAdapter{unmarshaler}
 }
 
+// unmarshal protobuf data, v must be a proto.Message
+func UnmarshalProtobuf(data []byte, v interface{}) (err error) {
+	message, ok := v.(proto.Message)
+	if !ok {
+		err = MustPassProtoMessageError(reflect.TypeOf(v))
+	}
+
+	if err == nil {
+		err = proto.Unmarshal(data, message)
+	}
+	return
+}
+
 func (fn UnmarshalFunc) Unmarshal(data []byte, v interface{}) error {
 	return fn(data, v)
 }
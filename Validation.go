@@ -0,0 +1,250 @@
+package gotten
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// formats of `format` tag
+const (
+	FormatEmail = "email"
+	FormatUUID  = "uuid"
+
+	// separator of values in `enum` tag
+	EnumSeparator = ","
+)
+
+// kinds of values validated
+const (
+	validateString = iota // string, fmt.Stringer or FilePath, min, max and len are of runes
+	validateNumber        // int, min and max are of value
+	validateLength        // slice, array or map, min, max and len are of elements
+)
+
+type (
+	// FieldError is a failed rule of a field of params
+	FieldError struct {
+		Field string // name of field
+		Rule  string // min, max, len, pattern, enum or format
+		Param string // value of the tag
+		Value string // RedactedValue if the field is sensitive
+	}
+
+	// ValidationError reports all failed rules before request is sent
+	ValidationError struct {
+		Errors []*FieldError
+	}
+
+	// rules of `min`, `max`, `len`, `pattern`, `enum` and `format` tags; empty values are checked by `require` only
+	validator struct {
+		name      string
+		kind      int
+		sensitive bool
+		min       *int64
+		max       *int64
+		length    *int64
+		pattern   *regexp.Regexp
+		enum      []string
+		format    string
+		tag       reflect.StructTag
+	}
+)
+
+var (
+	uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+func (err *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s(%s) is not satisfied by %q", err.Field, err.Rule, err.Param, err.Value)
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, 0, len(err.Errors))
+	for _, fieldErr := range err.Errors {
+		messages = append(messages, fieldErr.Error())
+	}
+	return ValidationFailed + ": " + strings.Join(messages, "; ")
+}
+
+// nil if no rule is set; rules of fields other than strings, ints, slices, arrays and maps are unsupported
+func newValidator(field reflect.StructField, sensitive bool) (v *validator, err error) {
+	tag := field.Tag
+	var rule string
+	for _, key := range []string{KeyMin, KeyMax, KeyLen, KeyPattern, KeyEnum, KeyFormat} {
+		if tag.Get(key) != ZeroStr {
+			rule = key
+			break
+		}
+	}
+	if rule == ZeroStr {
+		return
+	}
+
+	v = &validator{name: field.Name, sensitive: sensitive, tag: tag}
+	switch fieldType := field.Type; {
+	case fieldType == IntType:
+		v.kind = validateNumber
+	case fieldType.Kind() == reflect.String || fieldType.Implements(StringerType):
+		v.kind = validateString
+	case fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array || fieldType.Kind() == reflect.Map:
+		v.kind = validateLength
+	default:
+		return nil, UnsupportedValidationTypeError(field.Name, rule, fieldType)
+	}
+
+	if v.min, err = v.parseInt(KeyMin); err == nil {
+		if v.max, err = v.parseInt(KeyMax); err == nil {
+			v.length, err = v.parseInt(KeyLen)
+		}
+	}
+
+	if err == nil && v.kind == validateNumber && v.length != nil {
+		err = InvalidValidationRuleError(field.Name, KeyLen, tag.Get(KeyLen))
+	}
+
+	if raw := tag.Get(KeyPattern); err == nil && raw != ZeroStr {
+		if v.kind == validateLength {
+			err = InvalidValidationRuleError(field.Name, KeyPattern, raw)
+		} else if v.pattern, err = regexp.Compile(raw); err != nil {
+			err = InvalidValidationRuleError(field.Name, KeyPattern, raw)
+		}
+	}
+
+	if raw := tag.Get(KeyEnum); err == nil && raw != ZeroStr {
+		if v.kind == validateLength {
+			err = InvalidValidationRuleError(field.Name, KeyEnum, raw)
+		}
+		v.enum = strings.Split(raw, EnumSeparator)
+	}
+
+	if v.format = tag.Get(KeyFormat); err == nil && v.format != ZeroStr {
+		switch {
+		case v.kind == validateLength:
+			fallthrough
+		case v.format != FormatEmail && v.format != FormatUUID:
+			err = InvalidValidationRuleError(field.Name, KeyFormat, v.format)
+		}
+	}
+
+	if err != nil {
+		v = nil
+	}
+	return
+}
+
+func (v *validator) parseInt(key string) (result *int64, err error) {
+	if raw := v.tag.Get(key); raw != ZeroStr {
+		var value int64
+		if value, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			err = InvalidValidationRuleError(v.name, key, raw)
+		}
+		result = &value
+	}
+	return
+}
+
+// empty value is not validated
+func (v *validator) validateString(value string) (errs []*FieldError) {
+	if v == nil || value == ZeroStr {
+		return
+	}
+
+	if v.kind == validateNumber {
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			errs = v.validateSize(number, value, false)
+		}
+	} else {
+		errs = v.validateSize(int64(utf8.RuneCountInString(value)), value, true)
+	}
+
+	if v.pattern != nil && !v.pattern.MatchString(value) {
+		errs = append(errs, v.fieldError(KeyPattern, value))
+	}
+
+	if v.enum != nil && !v.inEnum(value) {
+		errs = append(errs, v.fieldError(KeyEnum, value))
+	}
+
+	if v.format != ZeroStr && !validFormat(v.format, value) {
+		errs = append(errs, v.fieldError(KeyFormat, value))
+	}
+	return
+}
+
+// value of field before default value is applied;
+// nil, zero or empty value is not validated
+func (v *validator) validateValue(value reflect.Value) (errs []*FieldError) {
+	if v == nil {
+		return
+	}
+
+	switch v.kind {
+	case validateLength:
+		if value.Len() > 0 {
+			errs = v.validateSize(int64(value.Len()), fmt.Sprintf("len(%d)", value.Len()), true)
+		}
+	default:
+		// named string types, like FilePath, are validated by kind
+		switch value.Kind() {
+		case reflect.String:
+			errs = v.validateString(value.String())
+		case reflect.Int:
+			if value.Int() != ZeroInt {
+				errs = v.validateString(strconv.FormatInt(value.Int(), 10))
+			}
+		default:
+			if val, ok := value.Interface().(fmt.Stringer); ok && (value.Kind() != reflect.Ptr || !value.IsNil()) {
+				errs = v.validateString(val.String())
+			}
+		}
+	}
+	return
+}
+
+func (v *validator) validateSize(size int64, value string, checkLen bool) (errs []*FieldError) {
+	if v.min != nil && size < *v.min {
+		errs = append(errs, v.fieldError(KeyMin, value))
+	}
+
+	if v.max != nil && size > *v.max {
+		errs = append(errs, v.fieldError(KeyMax, value))
+	}
+
+	if checkLen && v.length != nil && size != *v.length {
+		errs = append(errs, v.fieldError(KeyLen, value))
+	}
+	return
+}
+
+func (v *validator) inEnum(value string) bool {
+	for _, item := range v.enum {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) fieldError(rule, value string) *FieldError {
+	if v.sensitive {
+		value = RedactedValue
+	}
+	return &FieldError{Field: v.name, Rule: rule, Param: v.tag.Get(rule), Value: value}
+}
+
+func validFormat(format, value string) (valid bool) {
+	switch format {
+	case FormatEmail:
+		address, err := mail.ParseAddress(value)
+		valid = err == nil && address.Address == value
+	case FormatUUID:
+		valid = uuidRegexp.MatchString(value)
+	}
+	return
+}
@@ -10,10 +10,9 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -38,10 +37,12 @@ type (
 	VarsController interface {
 		setValues(value reflect.Value) error
 		getUrl() (*url.URL, error)
-		getBody() (io.Reader, error)
+		getBody() (*requestBody, error)
 		getHeader() http.Header
 		getContentType() string
 		getCookies() []*http.Cookie
+		getProgress() Progress
+		abandon()
 	}
 
 	VarsParser struct {
@@ -51,6 +52,13 @@ type (
 		contentType  string
 		fieldTable   []*Field
 		ioFieldTable []*IOField
+		marshalers   BodyMarshalers
+		// indexes of fields of TypeMultipart, sorted by order
+		partIndexes []int
+		// index of field of type Progress, -1 if absent
+		progressIndex int
+		// boundary of multipart body, random for every call if empty
+		boundary string
 	}
 
 	VarsCtr struct {
@@ -62,53 +70,59 @@ type (
 		pathValues       map[string]string
 		queryValues      url.Values
 		formValues       url.Values
-		multipartValues  map[string]string
-		multipartFiles   map[string]string
-		multipartReaders map[string]MultipartReader
+		partIndexes      []int
+		partAdders       [][]partAdder // indexed by field
+		closers          []io.Closer   // closable readers of parts, closed if request is abandoned
 		header           http.Header
 		cookies          []*http.Cookie
+		validationErrors []*FieldError
 		body             io.Reader
-		writer           *multipart.Writer
+		boundary         string
+		progressIndex    int
+		progress         Progress
 	}
 
-	// TypePath, TypeQuery, TypeForm, TypeHeader, TypeCookie, TypeMultipart(except io.Reader)
+	// TypePath, TypeQuery, TypeForm, TypeHeader, TypeCookie, TypeMultipart(except io.Reader);
+	// getValueFunc of file parts is nil
 	Field struct {
 		key          string
 		name         string
 		defaultValue string
 		valueType    string
 		require      bool
+		sensitive    bool
+		validator    *validator
 		fieldType    reflect.Type
 		// can only called by getValue
 		getValueFunc func(value reflect.Value) (string, error)
 	}
 
-	// TypeJSON, TypeXML, TypeMultipart(io.Reader)
+	// TypeMultipart(io.Reader), and types of BodyMarshalers, like TypeJSON, TypeXML
 	IOField struct {
 		key          string
 		name         string
 		defaultValue string
 		valueType    string
+		contentType  string // of parts, by the BodyMarshaler
 		require      bool
+		sensitive    bool
+		validator    *validator
 		// can only called by getValue
 		getReaderFunc func(value reflect.Value) (Reader, error)
 	}
 
-	MultipartReader struct {
-		reader io.Reader
-		header http.Header
-	}
-
 	PathKeyList map[string]bool
 )
 
-func newVarsParser(path string) (*VarsParser, error) {
+func newVarsParser(path string, marshalers BodyMarshalers) (*VarsParser, error) {
 	// fieldTable and ioFieldTables will be made after num of fields is known
 	pathKeys, err := getPathKeys(pathKeyRegexp, path)
 	return &VarsParser{
-		regex:    pathKeyRegexp,
-		path:     path,
-		pathKeys: pathKeys,
+		regex:         pathKeyRegexp,
+		path:          path,
+		pathKeys:      pathKeys,
+		marshalers:    marshalers,
+		progressIndex: -1,
 	}, err
 }
 
@@ -181,8 +195,15 @@ func (parser *VarsParser) addField(index int, valueType string, field reflect.St
 	fieldTag := field.Tag
 	key := processKey(fieldTag.Get(KeyKey), valueType, field.Name)
 	defaultValue := fieldTag.Get(KeyDefault)
-	require, parseErr := processRequired(fieldTag.Get(KeyRequire))
-	if err = parseErr; err == nil {
+	var require, sensitive bool
+	var fieldValidator *validator
+	if require, err = processRequired(fieldTag.Get(KeyRequire)); err == nil {
+		sensitive, err = processSensitive(fieldTag.Get(KeySensitive))
+	}
+	if err == nil {
+		fieldValidator, err = newValidator(field, sensitive)
+	}
+	if err == nil {
 		parser.fieldTable[index] = &Field{
 			key:          key,
 			name:         field.Name,
@@ -190,6 +211,8 @@ func (parser *VarsParser) addField(index int, valueType string, field reflect.St
 			valueType:    valueType,
 			fieldType:    fieldType,
 			require:      require,
+			sensitive:    sensitive,
+			validator:    fieldValidator,
 		}
 		switch valueType {
 		case TypePath:
@@ -208,7 +231,9 @@ func (parser *VarsParser) addField(index int, valueType string, field reflect.St
 		case TypeForm:
 			parser.fieldTable[index].getValueFunc, err = getValueGetterFunc(fieldType, TypePath)
 		case TypeMultipart:
-			parser.fieldTable[index].getValueFunc, err = getMultipartValueGetterFunc(fieldType, TypePath)
+			if !isFilePartType(fieldType) {
+				parser.fieldTable[index].getValueFunc, err = getMultipartValueGetterFunc(fieldType, TypePath)
+			}
 			//default:
 			// never occur
 		}
@@ -220,27 +245,35 @@ func (parser *VarsParser) addField(index int, valueType string, field reflect.St
 func (parser *VarsParser) addIOField(index int, valueType string, field reflect.StructField) (err error) {
 	fieldType := field.Type
 	fieldTag := field.Tag
-	key := processKey(fieldTag.Get(KeyKey), valueType, field.Name)
+	key := parser.processKey(fieldTag.Get(KeyKey), valueType, field.Name)
 	defaultValue := fieldTag.Get(KeyDefault)
-	require, parseErr := processRequired(fieldTag.Get(KeyRequire))
-	if err = parseErr; err == nil {
+	var require, sensitive bool
+	var fieldValidator *validator
+	if require, err = processRequired(fieldTag.Get(KeyRequire)); err == nil {
+		sensitive, err = processSensitive(fieldTag.Get(KeySensitive))
+	}
+	if err == nil {
+		fieldValidator, err = newValidator(field, sensitive)
+	}
+	if err == nil {
 		parser.ioFieldTable[index] = &IOField{
 			key:          key,
 			name:         field.Name,
 			defaultValue: defaultValue,
 			valueType:    valueType,
 			require:      require,
+			sensitive:    sensitive,
+			validator:    fieldValidator,
 		}
 
 		switch valueType {
-		case TypeJSON:
-			parser.ioFieldTable[index].getReaderFunc, err = getJSONReaderGetterFunc(fieldType, valueType)
-		case TypeXML:
-			parser.ioFieldTable[index].getReaderFunc, err = getXMLReaderGetterFunc(fieldType, valueType)
 		case TypeMultipart:
 			parser.ioFieldTable[index].getReaderFunc = getReaderFromReader
-			//default:
-			// never occur
+		default:
+			// marshaler must exist, checked by parse
+			marshaler := parser.marshalers[valueType]
+			parser.ioFieldTable[index].contentType = marshaler.PartContentType()
+			parser.ioFieldTable[index].getReaderFunc, err = marshaler.getReaderGetterFunc(fieldType, valueType)
 		}
 	}
 	return
@@ -256,6 +289,11 @@ func (parser *VarsParser) parse(paramType reflect.Type) (err error) {
 		parser.ioFieldTable = make([]*IOField, paramElem.NumField())
 		for i := 0; i < paramElem.NumField(); i++ {
 			field := paramElem.Field(i)
+			if field.Type == ProgressType && fieldExportable(field.Name) {
+				parser.progressIndex = i
+				continue
+			}
+
 			if fieldExportable(field.Name) {
 				valueType := field.Tag.Get(KeyType)
 				switch valueType {
@@ -272,18 +310,11 @@ func (parser *VarsParser) parse(paramType reflect.Type) (err error) {
 					if err == nil {
 						err = parser.addField(i, valueType, field)
 					}
-				case TypeJSON:
-					err = parser.checkContentType(headers.MIMEApplicationJSONCharsetUTF8)
-					if err == nil {
-						err = parser.addIOField(i, valueType, field)
-					}
-				case TypeXML:
-					err = parser.checkContentType(headers.MIMEApplicationXMLCharsetUTF8)
-					if err == nil {
-						err = parser.addIOField(i, valueType, field)
-					}
 				case TypeMultipart:
 					err = parser.checkContentType(headers.MIMEMultipartForm)
+					if err == nil {
+						err = parser.addPartIndex(i, field)
+					}
 					if err == nil {
 						if field.Type == ReaderType {
 							err = parser.addIOField(i, valueType, field)
@@ -292,7 +323,18 @@ func (parser *VarsParser) parse(paramType reflect.Type) (err error) {
 						}
 					}
 				default:
-					err = UnsupportedValueTypeError(valueType)
+					marshaler, ok := parser.marshalers[valueType]
+					if !ok {
+						err = UnsupportedValueTypeError(valueType)
+						break
+					}
+					err = parser.checkContentType(marshaler.contentType)
+					if err == nil {
+						err = parser.addIOField(i, valueType, field)
+					}
+					if err == nil {
+						err = parser.addPartIndex(i, field)
+					}
 				}
 				if err != nil {
 					break
@@ -302,89 +344,101 @@ func (parser *VarsParser) parse(paramType reflect.Type) (err error) {
 		if err == nil && !parser.pathKeys.empty() {
 			err = SomePathVarHasNoValueError(parser.pathKeys)
 		}
+		if err == nil {
+			parser.sortPartIndexes(paramElem)
+		}
 	}
 
 	return
 }
 
+// can only be called by parse; body marshaled by BodyMarshaler is a part if content type is multipart
+func (parser *VarsParser) addPartIndex(index int, field reflect.StructField) (err error) {
+	raw := field.Tag.Get(KeyOrder)
+	if _, err = processOrder(raw); err != nil {
+		err = InvalidFieldTagError(field.Name, KeyOrder, raw)
+		return
+	}
+	parser.partIndexes = append(parser.partIndexes, index)
+	return
+}
+
+// parts are in order of fields, unless order tag is set;
+// field without order tag is of order 0
+func (parser *VarsParser) sortPartIndexes(paramElem reflect.Type) {
+	if parser.contentType != headers.MIMEMultipartForm {
+		parser.partIndexes = nil
+		return
+	}
+
+	sort.SliceStable(parser.partIndexes, func(i, j int) bool {
+		orderI, _ := processOrder(paramElem.Field(parser.partIndexes[i]).Tag.Get(KeyOrder))
+		orderJ, _ := processOrder(paramElem.Field(parser.partIndexes[j]).Tag.Get(KeyOrder))
+		return orderI < orderJ
+	})
+}
+
 // can only be called by checkContentType
 func (parser *VarsParser) setContentType(contentType string) {
 	parser.contentType = contentType
 }
 
-// can only be called by parse()
+// can only be called by parse();
+// contentType is MIMEApplicationForm, MIMEMultipartForm or content type of a BodyMarshaler,
+// body marshaled by BodyMarshaler can be embedded into form or multipart
 func (parser *VarsParser) checkContentType(contentType string) (err error) {
 	switch contentType {
 	case headers.MIMEMultipartForm:
 		switch parser.contentType {
 		case headers.MIMEApplicationForm:
 			err = ContentTypeConflictError(parser.contentType, contentType)
-		case ZeroStr:
-			fallthrough
-		case headers.MIMEApplicationJSONCharsetUTF8:
-			fallthrough
-		case headers.MIMEApplicationXMLCharsetUTF8:
-			parser.setContentType(contentType)
-		case headers.MIMEMultipartForm:
 		default:
-			panic("Unsupported content type of parser: " + parser.contentType)
+			parser.setContentType(contentType)
 		}
 	case headers.MIMEApplicationForm:
 		switch parser.contentType {
 		case headers.MIMEMultipartForm:
 			err = ContentTypeConflictError(parser.contentType, contentType)
-		case ZeroStr:
-			fallthrough
-		case headers.MIMEApplicationJSONCharsetUTF8:
-			fallthrough
-		case headers.MIMEApplicationXMLCharsetUTF8:
-			parser.setContentType(contentType)
-		case headers.MIMEApplicationForm:
 		default:
-			panic("Unsupported content type of parser: " + parser.contentType)
+			parser.setContentType(contentType)
 		}
-	case headers.MIMEApplicationJSONCharsetUTF8:
-		fallthrough
-	case headers.MIMEApplicationXMLCharsetUTF8:
+	default:
 		switch parser.contentType {
-		case headers.MIMEApplicationXMLCharsetUTF8:
-			fallthrough
-		case headers.MIMEApplicationJSONCharsetUTF8:
-			err = ContentTypeConflictError(parser.contentType, contentType)
 		case ZeroStr:
 			parser.setContentType(contentType)
 		case headers.MIMEApplicationForm:
 		case headers.MIMEMultipartForm:
 		default:
-			panic("Unsupported content type of parser: " + parser.contentType)
+			// only one marshaled body
+			err = ContentTypeConflictError(parser.contentType, contentType)
 		}
-	default:
-		panic("Unsupported content type: " + contentType)
 	}
 	return
 }
 
 func (parser *VarsParser) Build() VarsController {
 	varsCtr := &VarsCtr{
-		regex:        parser.regex,
-		path:         parser.path,
-		contentType:  parser.contentType,
-		fieldTable:   parser.fieldTable,
-		ioFieldTable: parser.ioFieldTable,
-		pathValues:   make(map[string]string),
-		queryValues:  make(url.Values),
-		header:       make(http.Header),
+		regex:         parser.regex,
+		path:          parser.path,
+		contentType:   parser.contentType,
+		fieldTable:    parser.fieldTable,
+		ioFieldTable:  parser.ioFieldTable,
+		progressIndex: parser.progressIndex,
+		pathValues:    make(map[string]string),
+		queryValues:   make(url.Values),
+		header:        make(http.Header),
 	}
 
 	if varsCtr.contentType != ZeroStr {
-		body := bytes.NewBuffer(make([]byte, 0))
-		varsCtr.body = body
+		varsCtr.body = bytes.NewBuffer(make([]byte, 0))
 
 		if varsCtr.contentType == headers.MIMEMultipartForm {
-			varsCtr.multipartValues = make(map[string]string)
-			varsCtr.multipartReaders = make(map[string]MultipartReader)
-			varsCtr.multipartFiles = make(map[string]string)
-			varsCtr.writer = multipart.NewWriter(body)
+			varsCtr.partIndexes = parser.partIndexes
+			varsCtr.partAdders = make([][]partAdder, len(parser.fieldTable))
+			varsCtr.boundary = parser.boundary
+			if varsCtr.boundary == ZeroStr {
+				varsCtr.boundary = multipart.NewWriter(nil).Boundary()
+			}
 		}
 
 		if varsCtr.contentType == headers.MIMEApplicationForm {
@@ -411,68 +465,48 @@ func (varsCtr VarsCtr) getCookies() []*http.Cookie {
 
 func (varsCtr VarsCtr) getContentType() (contentType string) {
 	if varsCtr.contentType == headers.MIMEMultipartForm {
-		contentType = varsCtr.writer.FormDataContentType()
+		contentType = varsCtr.multipartParts().contentType()
 	} else {
 		contentType = varsCtr.contentType
 	}
 	return
 }
 
+func (varsCtr VarsCtr) getProgress() Progress {
+	return varsCtr.progress
+}
+
 func (varsCtr VarsCtr) getHeader() http.Header {
 	return varsCtr.header
 }
 
-func (varsCtr VarsCtr) getBody() (body io.Reader, err error) {
+func (varsCtr VarsCtr) getBody() (body *requestBody, err error) {
 	switch varsCtr.contentType {
 	case headers.MIMEApplicationForm:
-		body = bytes.NewBufferString(varsCtr.formValues.Encode())
+		body = newReaderBody(bytes.NewBufferString(varsCtr.formValues.Encode()))
 	case headers.MIMEMultipartForm:
-		err = varsCtr.resolveMultipartBody()
-		fallthrough
-	case headers.MIMEApplicationXMLCharsetUTF8:
-		fallthrough
-	case headers.MIMEApplicationJSONCharsetUTF8:
-		body = varsCtr.body
+		parts := varsCtr.multipartParts()
+		err = varsCtr.resolveMultipartParts(parts)
+		body = newMultipartBody(parts)
+	default:
+		body = newReaderBody(varsCtr.body)
 	}
 	return
 }
 
-func (varsCtr VarsCtr) resolveMultipartBody() (err error) {
-	var partWriter io.Writer
-	writer := varsCtr.writer
-	for key, val := range varsCtr.multipartValues {
-		writer.WriteField(key, val)
-	}
-
-	for key, reader := range varsCtr.multipartReaders {
-		if partWriter, err = writer.CreateFormField(key); err == nil {
-			_, err = io.Copy(partWriter, reader.reader)
-		}
-
-		if x, ok := reader.reader.(io.Closer); ok {
-			x.Close()
-		}
-
-		if err != nil {
-			break
-		}
-	}
+func (varsCtr VarsCtr) multipartParts() *multipartParts {
+	return &multipartParts{boundary: varsCtr.boundary}
+}
 
-	for key, path := range varsCtr.multipartFiles {
-		var file *os.File
-		file, err = os.Open(path)
-		if err == nil {
-			if partWriter, err = writer.CreateFormFile(key, filepath.Base(file.Name())); err == nil {
-				_, err = io.Copy(partWriter, file)
+// parts are streamed when request is sent, in order of partIndexes
+func (varsCtr VarsCtr) resolveMultipartParts(parts *multipartParts) (err error) {
+	for _, index := range varsCtr.partIndexes {
+		for _, addPart := range varsCtr.partAdders[index] {
+			if err = addPart(parts); err != nil {
+				return
 			}
 		}
-		file.Close() // close
-		if err != nil {
-			break
-		}
 	}
-
-	writer.Close()
 	return
 }
 
@@ -480,6 +514,7 @@ func (varsCtr *VarsCtr) setValuesByFields(value reflect.Value) (err error) {
 	for i, field := range varsCtr.fieldTable {
 		if field != nil {
 			fieldValue := value.Field(i)
+			varsCtr.validationErrors = append(varsCtr.validationErrors, field.validator.validateValue(fieldValue)...)
 			var val string
 			switch field.valueType {
 			case TypePath:
@@ -505,11 +540,13 @@ func (varsCtr *VarsCtr) setValuesByFields(value reflect.Value) (err error) {
 					varsCtr.formValues.Add(field.key, val)
 				}
 			case TypeMultipart:
+				if isFilePartType(field.fieldType) {
+					err = varsCtr.addFileParts(i, field, fieldValue)
+					break
+				}
 				val, err = field.getValue(fieldValue)
-				if field.fieldType == FilePathType {
-					varsCtr.multipartFiles[field.key] = val
-				} else {
-					varsCtr.multipartValues[field.key] = val
+				if err == nil {
+					varsCtr.addValuePart(i, field, val)
 				}
 				//default:
 				// never occur
@@ -523,38 +560,59 @@ func (varsCtr *VarsCtr) setValuesByFields(value reflect.Value) (err error) {
 	return
 }
 
+func (varsCtr *VarsCtr) addValuePart(index int, field *Field, val string) {
+	key := field.key
+	if field.fieldType == FilePathType {
+		varsCtr.partAdders[index] = []partAdder{func(parts *multipartParts) error {
+			return parts.addFile(key, val)
+		}}
+	} else {
+		varsCtr.partAdders[index] = []partAdder{func(parts *multipartParts) error {
+			parts.addValue(key, val)
+			return nil
+		}}
+	}
+}
+
+func (varsCtr *VarsCtr) addFileParts(index int, field *Field, value reflect.Value) (err error) {
+	adders, _ := getFilePartAdders(field.key, value)
+	if len(adders) == 0 && field.require {
+		err = EmptyRequiredVariableError(field.name)
+	}
+	varsCtr.partAdders[index] = adders
+	return
+}
+
+func (varsCtr *VarsCtr) addReaderPart(index int, field *IOField, reader Reader, contentType string) {
+	header := make(http.Header)
+	header.Add(headers.HeaderContentType, contentType)
+	key := field.key
+	varsCtr.partAdders[index] = []partAdder{func(parts *multipartParts) error {
+		return parts.addReader(key, reader, header)
+	}}
+}
+
 func (varsCtr *VarsCtr) setValuesByIOFields(value reflect.Value) (err error) {
 	for i, field := range varsCtr.ioFieldTable {
 		if field != nil {
 			fieldValue := value.Field(i)
+			varsCtr.validationErrors = append(varsCtr.validationErrors, field.validator.validateValue(fieldValue)...)
 			var reader Reader
 			switch field.valueType {
-			case TypeJSON:
+			case TypeMultipart:
 				switch varsCtr.contentType {
-				case headers.MIMEApplicationJSONCharsetUTF8:
-					reader, err = field.getValue(fieldValue)
-					varsCtr.body = reader
-				case headers.MIMEApplicationForm:
-					var data []byte
-					reader, err = field.getValue(fieldValue)
-					if err == nil && !reader.Empty() {
-						data, err = ioutil.ReadAll(reader)
-						varsCtr.formValues.Add(field.key, string(data))
-					}
 				case headers.MIMEMultipartForm:
-					header := make(http.Header)
-					header.Add(headers.HeaderContentType, headers.MIMEApplicationJavaScriptCharsetUTF8)
 					reader, err = field.getValue(fieldValue)
-					varsCtr.multipartReaders[field.key] = MultipartReader{reader, header}
+					if err == nil {
+						varsCtr.addReaderPart(i, field, reader, headers.MIMEOctetStream)
+					}
 					//default:
 					// never occur
 					//panic("Unsupported content type: " + varsCtr.contentType)
 				}
-			case TypeXML:
+			default:
+				// types of BodyMarshalers
 				switch varsCtr.contentType {
-				case headers.MIMEApplicationXMLCharsetUTF8:
-					reader, err = field.getValue(fieldValue)
-					varsCtr.body = reader
 				case headers.MIMEApplicationForm:
 					var data []byte
 					reader, err = field.getValue(fieldValue)
@@ -563,28 +621,14 @@ func (varsCtr *VarsCtr) setValuesByIOFields(value reflect.Value) (err error) {
 						varsCtr.formValues.Add(field.key, string(data))
 					}
 				case headers.MIMEMultipartForm:
-					header := make(http.Header)
-					header.Add(headers.HeaderContentType, headers.MIMEApplicationXMLCharsetUTF8)
 					reader, err = field.getValue(fieldValue)
-					varsCtr.multipartReaders[field.key] = MultipartReader{reader, header}
-					//default:
-					// never occur
-					//panic("Unsupported content type: " + varsCtr.contentType)
-				}
-			case TypeMultipart:
-				switch varsCtr.contentType {
-				case headers.MIMEMultipartForm:
-					header := make(http.Header)
-					header.Add(headers.HeaderContentType, headers.MIMEOctetStream)
+					if err == nil {
+						varsCtr.addReaderPart(i, field, reader, field.contentType)
+					}
+				default:
 					reader, err = field.getValue(fieldValue)
-					varsCtr.multipartReaders[field.key] = MultipartReader{reader, header}
-					//default:
-					// never occur
-					//panic("Unsupported content type: " + varsCtr.contentType)
+					varsCtr.body = reader
 				}
-				//default:
-				// never occur
-				//panic(UnsupportedValueTypeError(field.valueType))
 			}
 			if err != nil {
 				break
@@ -596,13 +640,52 @@ func (varsCtr *VarsCtr) setValuesByIOFields(value reflect.Value) (err error) {
 
 func (varsCtr *VarsCtr) setValues(ptr reflect.Value) (err error) {
 	value := ptr.Elem()
+	if varsCtr.progressIndex >= 0 {
+		varsCtr.progress, _ = value.Field(varsCtr.progressIndex).Interface().(Progress)
+	}
+
+	varsCtr.collectClosers(value)
 	err = varsCtr.setValuesByFields(value)
 	if err == nil {
 		err = varsCtr.setValuesByIOFields(value)
 	}
+	if err == nil && len(varsCtr.validationErrors) > 0 {
+		err = &ValidationError{varsCtr.validationErrors}
+	}
 	return
 }
 
+// closable readers of all parts, even if setting values of them fails
+func (varsCtr *VarsCtr) collectClosers(value reflect.Value) {
+	if varsCtr.contentType != headers.MIMEMultipartForm {
+		return
+	}
+
+	for i, field := range varsCtr.fieldTable {
+		if field != nil && field.valueType == TypeMultipart && isFilePartType(field.fieldType) {
+			_, closers := getFilePartAdders(field.key, value.Field(i))
+			varsCtr.closers = append(varsCtr.closers, closers...)
+		}
+	}
+
+	for i, field := range varsCtr.ioFieldTable {
+		if field != nil {
+			if reader, ok := value.Field(i).Interface().(io.Reader); ok {
+				if closer, ok := unwrapReader(reader).(io.Closer); ok {
+					varsCtr.closers = append(varsCtr.closers, closer)
+				}
+			}
+		}
+	}
+}
+
+// close readers of parts if request is never sent
+func (varsCtr VarsCtr) abandon() {
+	for _, closer := range varsCtr.closers {
+		closer.Close()
+	}
+}
+
 // str: {key}
 func (varsCtr VarsCtr) findAndReplace(pattern string) string {
 	key := getKeyFromPattern(pattern)
@@ -617,6 +700,16 @@ func getKeyFromPattern(pattern string) string {
 	return strings.TrimRight(strings.TrimLeft(pattern, "{"), "}")
 }
 
+// key of fields of BodyMarshalers is snake case
+func (parser *VarsParser) processKey(rawKey, valueType, fieldName string) (key string) {
+	if _, ok := parser.marshalers[valueType]; ok && rawKey == ZeroStr {
+		key = strcase.ToSnake(fieldName)
+	} else {
+		key = processKey(rawKey, valueType, fieldName)
+	}
+	return
+}
+
 func processKey(rawKey, valueType, fieldName string) (key string) {
 	key = rawKey
 	if key == ZeroStr {
@@ -627,10 +720,6 @@ func processKey(rawKey, valueType, fieldName string) (key string) {
 			fallthrough
 		case TypeMultipart:
 			fallthrough
-		case TypeJSON:
-			fallthrough
-		case TypeXML:
-			fallthrough
 		case TypeForm:
 			fallthrough
 		case TypeCookie:
@@ -651,6 +740,20 @@ func processRequired(raw string) (required bool, err error) {
 	return
 }
 
+func processOrder(raw string) (order int, err error) {
+	if raw != ZeroStr {
+		order, err = strconv.Atoi(raw)
+	}
+	return
+}
+
+func processSensitive(raw string) (sensitive bool, err error) {
+	if raw != ZeroStr {
+		sensitive, err = strconv.ParseBool(raw)
+	}
+	return
+}
+
 func fieldExportable(fieldName string) bool {
 	return unicode.IsUpper(bytes.Runes([]byte{fieldName[0]})[0])
 }
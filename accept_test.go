@@ -0,0 +1,98 @@
+package gotten_test
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/unmarshalers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type (
+	AcceptService struct {
+		GetCSV          func(*struct{}) (gotten.Response, error)     `path:"/csv"`
+		GetAcceptedCSV  func(*struct{}) (gotten.Response, error)     `path:"/csv" accept:"text/csv"`
+		GetCSVByHeaders func(*AcceptParams) (gotten.Response, error) `path:"/csv" accept:"text/csv"`
+	}
+
+	AcceptParams struct {
+		Accept string `type:"header"`
+	}
+
+	CSVUser struct {
+		Uid      int
+		Username string
+	}
+)
+
+func TestConditionalUnmarshalers_Accept(t *testing.T) {
+	assert.Equal(t,
+		"application/json, application/xml;q=0.9, text/xml;q=0.9, application/protobuf;q=0.8, application/x-protobuf;q=0.8, text/html;q=0.7",
+		gotten.ConditionalUnmarshalers(gotten.DefaultUnmarshalers).Accept(),
+	)
+
+	all := gotten.ConditionalUnmarshalers{
+		gotten.NewConditionalUnmarshaler(unmarshalers.CSV, unmarshalers.CSVChecker),
+		gotten.NewConditionalUnmarshaler(unmarshalers.YAML, new(gotten.CheckerFactory).WhenStatuses(http.StatusOK).CreateAccept()),
+		gotten.NewConditionalUnmarshaler(unmarshalers.YAML, unmarshalers.YAMLChecker),
+		gotten.NewConditionalUnmarshaler(unmarshalers.CSV, unmarshalers.CSVChecker),
+		gotten.NewConditionalUnmarshaler(unmarshalers.YAML, new(gotten.CheckerFactory).CreateAccept()),
+	}
+	assert.Equal(t, "text/csv, application/yaml;q=0.9, application/x-yaml;q=0.9, text/yaml;q=0.9, */*;q=0.8", all.Accept())
+}
+
+func TestNotAcceptable(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(AcceptService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetCSV(&struct{}{})
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), gotten.NotAcceptable))
+	assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode())
+	data, err := ioutil.ReadAll(resp.Body())
+	assert.Nil(t, err)
+	assert.Equal(t, gotten.ConditionalUnmarshalers(gotten.DefaultUnmarshalers).Accept(), string(data))
+}
+
+func TestAccept(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		AddReaderUnmarshaler(unmarshalers.CSV, unmarshalers.CSVChecker).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(AcceptService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetCSV(&struct{}{})
+	assert.Nil(t, err)
+	var users []CSVUser
+	assert.Nil(t, resp.Unmarshal(&users))
+	assert.Equal(t, []CSVUser{{1, "Hexilee"}}, users)
+}
+
+func TestAcceptTag(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		AddHeader("Accept", "application/json").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(AcceptService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetAcceptedCSV(&struct{}{})
+	assert.NotNil(t, err) // no unmarshaler for csv, but accepted
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	resp, err = service.GetCSVByHeaders(&AcceptParams{"application/json"})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode())
+}
@@ -0,0 +1,463 @@
+// Package analyzer reports services which Creator.Impl would reject at runtime;
+// run it by `go vet -vettool=$(which gottenvet) ./...`
+package analyzer
+
+import (
+	"errors"
+	"github.com/Hexilee/gotten"
+	"github.com/iancoleman/strcase"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Doc = `check services implemented by gotten.Creator.Impl
+
+Reports unknown type values, path placeholders without matching fields,
+conflicting body types, unsupported field types, invalid require values
+and malformed struct tags of services and their params.`
+
+	GottenPath = "github.com/Hexilee/gotten"
+
+	// for Content-Type conflict check
+	contentTypeForm      = "form"
+	contentTypeMultipart = "multipart"
+)
+
+type (
+	// a service passed to Creator.Impl
+	serviceChecker struct {
+		pass  *analysis.Pass
+		call  *ast.CallExpr
+		files map[*token.File]bool
+	}
+)
+
+var (
+	Analyzer = &analysis.Analyzer{
+		Name:     "gotten",
+		Doc:      Doc,
+		Requires: []*analysis.Analyzer{inspect.Analyzer},
+		Run:      run,
+	}
+
+	// value types registered by Builder.RegisterMarshaler, separated by comma
+	extraTypes string
+
+	pathKeyRegexp = regexp.MustCompile(gotten.PathKeyRegexp)
+
+	ErrTagPairsNotSeparated = errors.New(`key:"value" pairs are not separated by spaces`)
+	ErrTagSyntax            = errors.New("bad syntax for struct tag")
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&extraTypes, "types", "", "value types registered by Builder.RegisterMarshaler, separated by comma")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	files := make(map[*token.File]bool)
+	for _, file := range pass.Files {
+		files[pass.Fset.File(file.Pos())] = true
+	}
+
+	checked := make(map[*types.Struct]bool)
+	pass.ResultOf[inspect.Analyzer].(*inspector.Inspector).Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(node ast.Node) {
+		call := node.(*ast.CallExpr)
+		if !isImplCall(pass, call) {
+			return
+		}
+
+		checker := &serviceChecker{pass: pass, call: call, files: files}
+		ptr, ok := pass.TypesInfo.TypeOf(call.Args[0]).Underlying().(*types.Pointer)
+		if !ok {
+			checker.report(token.NoPos, gotten.MustPassPtrToImpl)
+			return
+		}
+
+		service, ok := ptr.Elem().Underlying().(*types.Struct)
+		if !ok {
+			checker.report(token.NoPos, "%s: %s", gotten.ServiceMustBeStruct, ptr.Elem())
+			return
+		}
+
+		if !checked[service] {
+			checked[service] = true
+			checker.checkService(service)
+		}
+	})
+	return nil, nil
+}
+
+// (*gotten.Creator).Impl(service)
+func isImplCall(pass *analysis.Pass, call *ast.CallExpr) bool {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	return ok && len(call.Args) == 1 &&
+		fn.Name() == "Impl" &&
+		fn.Pkg() != nil && fn.Pkg().Path() == GottenPath &&
+		fn.Type().(*types.Signature).Recv() != nil
+}
+
+// report at pos if it is in the package, otherwise at the Impl call
+func (checker *serviceChecker) report(pos token.Pos, format string, args ...interface{}) {
+	if !pos.IsValid() || !checker.files[checker.pass.Fset.File(pos)] {
+		pos = checker.call.Pos()
+	}
+	checker.pass.Reportf(pos, format, args...)
+}
+
+func (checker *serviceChecker) checkService(service *types.Struct) {
+	// base of embedded Service is prefixed to path
+	var base string
+	for i := 0; i < service.NumFields(); i++ {
+		if field := service.Field(i); field.Embedded() && isNamed(field.Type(), GottenPath, "Service") {
+			base = reflect.StructTag(service.Tag(i)).Get(gotten.KeyBase)
+		}
+	}
+
+	for i := 0; i < service.NumFields(); i++ {
+		field := service.Field(i)
+		tag := service.Tag(i)
+		if err := validateTag(tag); err != nil {
+			checker.report(field.Pos(), "%s: malformed struct tag `%s`: %s", field.Name(), tag, err)
+			continue
+		}
+
+		if field.Embedded() && isNamed(field.Type(), GottenPath, "Service") {
+			checker.checkServiceTag(field, reflect.StructTag(tag))
+			continue
+		}
+
+		sig, ok := field.Type().Underlying().(*types.Signature)
+		if !ok || !field.Exported() ||
+			sig.Params().Len() != 1 ||
+			sig.Results().Len() != 2 ||
+			!isNamed(sig.Results().At(1).Type(), "", "error") ||
+			!isNamed(sig.Results().At(0).Type(), GottenPath, "Response") && !isPtrToNamed(sig.Results().At(0).Type(), "net/http", "Request") {
+			checker.report(field.Pos(), "%s: %s: %s", field.Name(), gotten.UnsupportedFuncType, field.Type())
+			continue
+		}
+
+		structTag := reflect.StructTag(tag)
+		if method := structTag.Get(gotten.KeyMethod); !isHTTPMethod(method) {
+			checker.report(field.Pos(), "%s: %s: %s", field.Name(), gotten.UnrecognizedHTTPMethod, method)
+		}
+		if raw, ok := structTag.Lookup(gotten.KeyHedge); ok {
+			if delay, err := time.ParseDuration(raw); err != nil || delay < 0 {
+				checker.report(field.Pos(), "%s: %s: %s:%q", field.Name(), gotten.InvalidFuncTag, gotten.KeyHedge, raw)
+			}
+		}
+		if raw, ok := structTag.Lookup(gotten.KeyRateLimit); ok {
+			if _, _, err := gotten.ParseRate(raw); err != nil {
+				checker.report(field.Pos(), "%s: %s: %s:%q", field.Name(), gotten.InvalidFuncTag, gotten.KeyRateLimit, raw)
+			}
+		}
+		if raw, ok := structTag.Lookup(gotten.KeyConcurrency); ok {
+			if concurrency, err := strconv.Atoi(raw); err != nil || concurrency <= 0 {
+				checker.report(field.Pos(), "%s: %s: %s:%q", field.Name(), gotten.InvalidFuncTag, gotten.KeyConcurrency, raw)
+			}
+		}
+		checker.checkParams(field, base+structTag.Get(gotten.KeyPath), sig.Params().At(0).Type())
+	}
+}
+
+// timeout must be a duration, headers must be like "Key: value\nKey: value"
+func (checker *serviceChecker) checkServiceTag(field *types.Var, tag reflect.StructTag) {
+	if raw := tag.Get(gotten.KeyTimeout); raw != gotten.ZeroStr {
+		if timeout, err := time.ParseDuration(raw); err != nil || timeout < 0 {
+			checker.report(field.Pos(), "%s: %s:%q", gotten.InvalidServiceTag, gotten.KeyTimeout, raw)
+		}
+	}
+
+	raw := tag.Get(gotten.KeyHeaders)
+	for _, line := range strings.Split(raw, gotten.HeaderSeparator) {
+		if line = strings.TrimSpace(line); line == gotten.ZeroStr {
+			continue
+		}
+		pair := strings.SplitN(line, ":", 2)
+		if key := strings.TrimSpace(pair[0]); len(pair) != 2 || key == gotten.ZeroStr || strings.ContainsAny(key, " \t") {
+			checker.report(field.Pos(), "%s: %s:%q", gotten.InvalidServiceTag, gotten.KeyHeaders, raw)
+			break
+		}
+	}
+}
+
+func (checker *serviceChecker) checkParams(fn *types.Var, path string, paramsType types.Type) {
+	ptr, ok := paramsType.Underlying().(*types.Pointer)
+	var params *types.Struct
+	if ok {
+		params, ok = ptr.Elem().Underlying().(*types.Struct)
+	}
+	if !ok {
+		checker.report(fn.Pos(), "%s: %s: %s", fn.Name(), gotten.ParamTypeMustBePtrOfStruct, paramsType)
+		return
+	}
+
+	pathKeys := make(map[string]bool)
+	for _, pattern := range pathKeyRegexp.FindAllString(path, -1) {
+		key := strings.Trim(pattern, "{}")
+		if pathKeys[key] {
+			checker.report(fn.Pos(), "%s: %s: %s", fn.Name(), gotten.DuplicatedPathKey, key)
+		}
+		pathKeys[key] = true
+	}
+
+	var contentType string
+	for i := 0; i < params.NumFields(); i++ {
+		field := params.Field(i)
+		tag := params.Tag(i)
+		if !field.Exported() {
+			continue
+		}
+
+		if err := validateTag(tag); err != nil {
+			checker.report(field.Pos(), "%s: malformed struct tag `%s`: %s", field.Name(), tag, err)
+			continue
+		}
+
+		if isNamed(field.Type(), GottenPath, "Progress") {
+			continue
+		}
+
+		structTag := reflect.StructTag(tag)
+		checker.checkBools(field, structTag)
+		valueType := structTag.Get(gotten.KeyType)
+		var fieldContentType string
+		switch valueType {
+		case gotten.TypePath:
+			key := structTag.Get(gotten.KeyKey)
+			if key == gotten.ZeroStr {
+				key = strcase.ToSnake(field.Name())
+			}
+			if !pathKeys[key] {
+				checker.report(field.Pos(), "%s: %s: %s", field.Name(), gotten.UnrecognizedPathKey, key)
+			}
+			delete(pathKeys, key)
+			fallthrough
+		case gotten.TypeQuery, gotten.TypeHeader, gotten.TypeCookie:
+			checker.checkFieldType(field, valueType, isValueType(field.Type()))
+		case gotten.TypeForm:
+			fieldContentType = contentTypeForm
+			checker.checkFieldType(field, valueType, isValueType(field.Type()))
+		case gotten.TypeMultipart:
+			fieldContentType = contentTypeMultipart
+			checker.checkFieldType(field, valueType, isPartType(field.Type()))
+		case gotten.TypeJSON, gotten.TypeXML:
+			fieldContentType = valueType
+			checker.checkFieldType(field, valueType, isBodyType(field.Type()))
+		case gotten.TypeProtobuf:
+			fieldContentType = valueType
+			checker.checkFieldType(field, valueType, isProtoMessage(field.Type()) || isReaderType(field.Type()))
+		default:
+			if !isExtraType(valueType) {
+				checker.report(field.Pos(), "%s: %s: %q", field.Name(), gotten.UnsupportedValueType, valueType)
+				continue
+			}
+			fieldContentType = valueType
+		}
+
+		if fieldContentType != gotten.ZeroStr {
+			var conflict bool
+			if contentType, conflict = nextContentType(contentType, fieldContentType); conflict {
+				checker.report(field.Pos(), "%s: %s%s, %s", field.Name(), gotten.ContentTypeConflict, contentType, fieldContentType)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(pathKeys))
+	for key := range pathKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		checker.report(fn.Pos(), "%s: %s: {%s}", fn.Name(), gotten.SomePathVarHasNoValue, key)
+	}
+}
+
+func (checker *serviceChecker) checkFieldType(field *types.Var, valueType string, supported bool) {
+	if !supported {
+		checker.report(field.Pos(), "%s: %s: %s of %s", field.Name(), gotten.UnsupportedFieldType, field.Type(), valueType)
+	}
+}
+
+// require and sensitive must be bool
+func (checker *serviceChecker) checkBools(field *types.Var, tag reflect.StructTag) {
+	for _, key := range []string{gotten.KeyRequire, gotten.KeySensitive} {
+		if raw := tag.Get(key); raw != gotten.ZeroStr {
+			if _, err := strconv.ParseBool(raw); err != nil {
+				checker.report(field.Pos(), "%s: invalid %s value %q, must be bool", field.Name(), key, raw)
+			}
+		}
+	}
+}
+
+// same as VarsParser.checkContentType; only one marshaled body unless it is embedded in form or multipart
+func nextContentType(current, next string) (contentType string, conflict bool) {
+	contentType = current
+	switch next {
+	case contentTypeForm, contentTypeMultipart:
+		if current == contentTypeForm || current == contentTypeMultipart {
+			conflict = current != next
+		}
+		if !conflict {
+			contentType = next
+		}
+	default:
+		switch current {
+		case gotten.ZeroStr:
+			contentType = next
+		case contentTypeForm, contentTypeMultipart:
+		default:
+			conflict = true
+		}
+	}
+	return
+}
+
+// same as reflect.StructTag.Get expects: optionally space-separated key:"value" pairs
+func validateTag(tag string) error {
+	for tag != gotten.ZeroStr {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == gotten.ZeroStr {
+			break
+		}
+
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return ErrTagSyntax
+		}
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return ErrTagSyntax
+		}
+		if _, err := strconv.Unquote(tag[:i+1]); err != nil {
+			return ErrTagSyntax
+		}
+		tag = tag[i+1:]
+		if tag != gotten.ZeroStr && tag[0] != ' ' {
+			return ErrTagPairsNotSeparated
+		}
+	}
+	return nil
+}
+
+func isHTTPMethod(method string) bool {
+	switch method {
+	case gotten.ZeroStr, http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isExtraType(valueType string) bool {
+	for _, extra := range strings.Split(extraTypes, ",") {
+		if extra = strings.TrimSpace(extra); extra != gotten.ZeroStr && extra == valueType {
+			return true
+		}
+	}
+	return false
+}
+
+// int, string or fmt.Stringer, for TypePath, TypeQuery, TypeHeader, TypeCookie and TypeForm
+func isValueType(fieldType types.Type) bool {
+	return isBasic(fieldType, types.Int) || isBasic(fieldType, types.String) || isNamed(fieldType, "fmt", "Stringer")
+}
+
+func isPartType(fieldType types.Type) bool {
+	if isValueType(fieldType) || isReaderType(fieldType) || isFileType(fieldType) {
+		return true
+	}
+
+	if ptr, ok := fieldType.(*types.Pointer); ok {
+		return isNamed(ptr.Elem(), GottenPath, "FilePart")
+	}
+
+	if slice, ok := fieldType.(*types.Slice); ok {
+		elem := slice.Elem()
+		if ptr, ok := elem.(*types.Pointer); ok {
+			return isNamed(ptr.Elem(), GottenPath, "FilePart")
+		}
+		return isFileType(elem)
+	}
+	return false
+}
+
+func isFileType(fieldType types.Type) bool {
+	return isNamed(fieldType, GottenPath, "FilePath") ||
+		isNamed(fieldType, GottenPath, "FilePart") ||
+		isNamed(fieldType, GottenPath, "FSFile")
+}
+
+// ptr, struct, slice and map are marshaled
+func isBodyType(fieldType types.Type) bool {
+	switch fieldType.Underlying().(type) {
+	case *types.Pointer, *types.Struct, *types.Slice, *types.Map:
+		return true
+	}
+	return isReaderType(fieldType)
+}
+
+// string, fmt.Stringer or io.Reader, read as body directly
+func isReaderType(fieldType types.Type) bool {
+	return isBasic(fieldType, types.String) || isNamed(fieldType, "fmt", "Stringer") || isNamed(fieldType, "io", "Reader")
+}
+
+func isProtoMessage(fieldType types.Type) bool {
+	switch fieldType.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		method, _, _ := types.LookupFieldOrMethod(fieldType, true, nil, "ProtoReflect")
+		_, ok := method.(*types.Func)
+		return ok
+	}
+	return false
+}
+
+// unnamed basic type
+func isBasic(fieldType types.Type, kind types.BasicKind) bool {
+	basic, ok := types.Unalias(fieldType).(*types.Basic)
+	return ok && basic.Kind() == kind
+}
+
+// pkgPath is empty for predeclared types
+func isNamed(fieldType types.Type, pkgPath, name string) bool {
+	named, ok := types.Unalias(fieldType).(*types.Named)
+	if !ok || named.Obj().Name() != name {
+		return false
+	}
+	if pkg := named.Obj().Pkg(); pkg != nil {
+		return pkg.Path() == pkgPath
+	}
+	return pkgPath == gotten.ZeroStr
+}
+
+func isPtrToNamed(fieldType types.Type, pkgPath, name string) bool {
+	ptr, ok := fieldType.(*types.Pointer)
+	return ok && isNamed(ptr.Elem(), pkgPath, name)
+}
@@ -0,0 +1,11 @@
+package analyzer_test
+
+import (
+	"github.com/Hexilee/gotten/analyzer"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"testing"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), analyzer.Analyzer, "a")
+}
@@ -0,0 +1,72 @@
+package a
+
+import (
+	"fmt"
+	"github.com/Hexilee/gotten"
+	"io"
+	"net/http"
+)
+
+type (
+	GoodParams struct {
+		Id       int                `type:"path"`
+		Page     int                `type:"query" require:"true"`
+		Token    fmt.Stringer       `type:"header" sensitive:"true"`
+		Avatar   io.Reader          `type:"part"`
+		Files    []gotten.FilePath  `type:"part"`
+		Extras   []*gotten.FilePart `type:"part"`
+		Meta     *Meta              `type:"json"`
+		Progress gotten.Progress
+		internal chan int
+	}
+
+	Meta struct {
+		Name string
+	}
+
+	GoodService struct {
+		gotten.Service `base:"/v2/{id}" timeout:"5s" headers:"X-Api-Version: 2\nX-Client: gotten\nAccept: text/html; q=0.9"`
+
+		Get    func(*GoodParams) (gotten.Response, error) `method:"POST" path:"/items" ratelimit:"10/s" concurrency:"4"`
+		Create func(*GoodParams) (*http.Request, error)   `method:"POST" path:"/items"`
+	}
+
+	BadParams struct {
+		Id      int             `type:"path" key:"item"` // want `Id: path key is unrecognized: item`
+		Page    int             `type:"qurey"`           // want `Page: field type is unrecognized: "qurey"`
+		Missing string          // want `Missing: field type is unrecognized: ""`
+		Ids     []int           `type:"query"`              // want `Ids: field type is unsupported: \[\]int of query`
+		Path    gotten.FilePath `type:"form"`               // want `Path: field type is unsupported: .*FilePath of form`
+		Force   bool            `type:"json"`               // want `Force: field type is unsupported: bool of json`
+		Name    string          `type:"part" require:"yes"` // want `Name: invalid require value "yes", must be bool` `Name: content type conflict: form, multipart`
+		Raw     string          `type:"xml"`
+		Body    *Meta           `type:"json"`
+		Secret  string          `type:"header";sensitive:"true"` // want `Secret: malformed struct tag .*: key:"value" pairs are not separated by spaces`
+	}
+
+	ConflictParams struct {
+		Raw  string `type:"xml"`
+		Body *Meta  `type:"json"` // want `Body: content type conflict: xml, json`
+	}
+
+	BadService struct {
+		gotten.Service `base:"/v2" timeout:"5 seconds" headers:"X-Api-Version: 2"` // want `tag of Service is invalid: timeout:"5 seconds"`
+
+		Put  func(*ConflictParams) (gotten.Response, error) `method:"PUT"`
+		Get  func(*BadParams) (gotten.Response, error)      `path:"/items/{id}"`               // want `Get: some pathValue has no value: \{id\}`
+		Post func(*GoodParams) (gotten.Response, error)     `method:"POST";path:"/items/{id}"` // want `Post: malformed struct tag .*: key:"value" pairs are not separated by spaces`
+		Send func(GoodParams) (gotten.Response, error)      `method:"SEND" hedge:"soon"`       // want `Send: http method is unrecognized: SEND` `Send: tag of function is invalid: hedge:"soon"` `Send: param type must be ptr of struct: a.GoodParams`
+		Poll func(GoodParams) (gotten.Response, error)      `ratelimit:"10" concurrency:"0"`   // want `Poll: tag of function is invalid: ratelimit:"10"` `Poll: tag of function is invalid: concurrency:"0"` `Poll: param type must be ptr of struct: a.GoodParams`
+		Do   func(*GoodParams) (*http.Response, error)      // want `Do: function type is not supported: .*`
+		Name string                                         // want `Name: function type is not supported: string`
+	}
+)
+
+func Impl(creator *gotten.Creator) {
+	creator.Impl(new(GoodService))
+	creator.Impl(new(BadService))
+	creator.Impl(new(BadService))
+	creator.Impl(GoodService{}) // want `must pass the ptr of the service to be implemented`
+	var id int
+	creator.Impl(&id) // want `service must be struct: int`
+}
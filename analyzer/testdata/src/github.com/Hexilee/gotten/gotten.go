@@ -0,0 +1,21 @@
+// Package gotten is a stub of github.com/Hexilee/gotten for analyzer tests
+package gotten
+
+import "io"
+
+type (
+	Creator  struct{}
+	Service  struct{}
+	Response interface{ StatusCode() int }
+	Progress func(int64)
+	FilePath string
+
+	FilePart struct {
+		Name   string
+		Reader io.Reader
+	}
+)
+
+func (creator *Creator) Impl(service interface{}) error {
+	return nil
+}
@@ -0,0 +1,368 @@
+package gotten_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/Hexilee/gotten/mock"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type (
+	BalancedService struct {
+		Get func(*BalancedParams) (gotten.Response, error) `path:"/host"`
+	}
+
+	ImpatientBalancedService struct {
+		gotten.Service `timeout:"10ms"`
+		Get            func(*BalancedParams) (gotten.Response, error) `path:"/host"`
+	}
+
+	BalancedParams struct {
+		User string `type:"query"`
+	}
+
+	// responds host and path, or 503 if the host is down, or nothing until canceled if the host hangs;
+	// content type is json to be unmarshaled
+	replicas struct {
+		mutex sync.Mutex
+		down  map[string]bool
+		hang  map[string]bool
+	}
+)
+
+func newReplicas(hosts ...string) (*replicas, gotten.Client) {
+	servers := &replicas{down: make(map[string]bool), hang: make(map[string]bool)}
+	builder := mock.NewClientBuilder()
+	for _, host := range hosts {
+		builder.RegisterFunc(host, servers.serve)
+	}
+	return servers, builder.Build()
+}
+
+func (servers *replicas) setDown(host string, down bool) {
+	servers.mutex.Lock()
+	defer servers.mutex.Unlock()
+	servers.down[host] = down
+}
+
+func (servers *replicas) setHang(host string, hang bool) {
+	servers.mutex.Lock()
+	defer servers.mutex.Unlock()
+	servers.hang[host] = hang
+}
+
+func (servers *replicas) serve(w http.ResponseWriter, r *http.Request) {
+	servers.mutex.Lock()
+	down, hang := servers.down[r.URL.Host], servers.hang[r.URL.Host]
+	servers.mutex.Unlock()
+	if hang {
+		<-r.Context().Done()
+		return
+	}
+	w.Header().Set(headers.HeaderContentType, headers.MIMEApplicationJSON)
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte(r.URL.Host + r.URL.Path))
+}
+
+func callHost(t *testing.T, service *BalancedService, user string) string {
+	resp, err := service.Get(&BalancedParams{user})
+	assert.Nil(t, err)
+	defer resp.Body().Close()
+	data, err := ioutil.ReadAll(resp.Body())
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestBalancer_RoundRobin(t *testing.T) {
+	_, client := newReplicas("a.mock.io", "b.mock.io", "c.mock.io")
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://a.mock.io/api").
+		AddEndpoint("https://b.mock.io", 1).
+		AddEndpoint("https://c.mock.io/v1/", 1).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+	assert.Len(t, creator.Endpoints(), 3)
+
+	service := new(BalancedService)
+	assert.Nil(t, creator.Impl(service))
+	var hosts []string
+	for i := 0; i < 6; i++ {
+		hosts = append(hosts, callHost(t, service, "hexilee"))
+	}
+	assert.Equal(t, []string{
+		"a.mock.io/api/host", "b.mock.io/host", "c.mock.io/v1/host",
+		"a.mock.io/api/host", "b.mock.io/host", "c.mock.io/v1/host",
+	}, hosts)
+}
+
+func TestBalancer_Weighted(t *testing.T) {
+	_, client := newReplicas("a.mock.io", "b.mock.io")
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 3).
+		AddEndpoint("https://b.mock.io", 1).
+		SetBalancer(gotten.Weighted()).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(BalancedService)
+	assert.Nil(t, creator.Impl(service))
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		counts[callHost(t, service, "hexilee")]++
+	}
+	assert.Equal(t, map[string]int{"a.mock.io/host": 6, "b.mock.io/host": 2}, counts)
+}
+
+func TestBalancer_LeastInFlight(t *testing.T) {
+	_, client := newReplicas("a.mock.io", "b.mock.io")
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		AddEndpoint("https://b.mock.io", 1).
+		SetBalancer(gotten.LeastInFlight()).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(BalancedService)
+	assert.Nil(t, creator.Impl(service))
+
+	// response of a is not closed
+	resp, err := service.Get(&BalancedParams{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), creator.Endpoints()[0].InFlight())
+	assert.Equal(t, "b.mock.io/host", callHost(t, service, "hexilee"))
+	assert.Equal(t, "b.mock.io/host", callHost(t, service, "hexilee"))
+
+	resp.Body().Close()
+	assert.Equal(t, int64(0), creator.Endpoints()[0].InFlight())
+	assert.Equal(t, "a.mock.io/host", callHost(t, service, "hexilee"))
+}
+
+func TestBalancer_ConsistentHash(t *testing.T) {
+	servers, client := newReplicas("a.mock.io", "b.mock.io", "c.mock.io")
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		AddEndpoint("https://b.mock.io", 1).
+		AddEndpoint("https://c.mock.io", 1).
+		SetBalancer(gotten.ConsistentHash(gotten.HashQuery("user"))).
+		SetOutlierEjection(1, time.Minute).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(BalancedService)
+	assert.Nil(t, creator.Impl(service))
+	hosts := make(map[string]string)
+	for _, user := range []string{"alice", "bob", "carol", "dave", "eve"} {
+		hosts[user] = callHost(t, service, user)
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, hosts[user], callHost(t, service, user))
+		}
+	}
+
+	// only keys of the ejected endpoint are moved
+	ejected := hosts["alice"]
+	servers.setDown(ejected[:len(ejected)-len("/host")], true)
+	resp, err := service.Get(&BalancedParams{"alice"})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode())
+	for user, host := range hosts {
+		if host == ejected {
+			assert.NotEqual(t, ejected, callHost(t, service, user))
+		} else {
+			assert.Equal(t, host, callHost(t, service, user))
+		}
+	}
+}
+
+func TestBalancer_ConsistentHashRecover(t *testing.T) {
+	servers, client := newReplicas("a.mock.io", "b.mock.io")
+	servers.setDown("b.mock.io", true)
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		AddEndpoint("https://b.mock.io", 1).
+		SetBalancer(gotten.ConsistentHash(gotten.HashQuery("user"))).
+		SetHealthCheck("/health", 10*time.Millisecond).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+	defer creator.StopHealthCheck()
+
+	assert.Eventually(t, func() bool {
+		return !creator.Endpoints()[1].Available()
+	}, time.Second, 5*time.Millisecond)
+
+	service := new(BalancedService)
+	assert.Nil(t, creator.Impl(service))
+	users := []string{"alice", "bob", "carol", "dave", "eve"}
+	for _, user := range users {
+		assert.Equal(t, "a.mock.io/host", callHost(t, service, user))
+	}
+
+	// b is on the ring even if it is down on the first request
+	servers.setDown("b.mock.io", false)
+	assert.Eventually(t, func() bool {
+		return creator.Endpoints()[1].Available()
+	}, time.Second, 5*time.Millisecond)
+	hosts := make(map[string]bool)
+	for _, user := range users {
+		hosts[callHost(t, service, user)] = true
+	}
+	assert.Equal(t, map[string]bool{"a.mock.io/host": true, "b.mock.io/host": true}, hosts)
+}
+
+func TestBalancer_OutlierEjection(t *testing.T) {
+	servers, client := newReplicas("a.mock.io", "b.mock.io")
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		AddEndpoint("https://b.mock.io", 1).
+		SetOutlierEjection(2, 50*time.Millisecond).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(BalancedService)
+	assert.Nil(t, creator.Impl(service))
+	servers.setDown("a.mock.io", true)
+	for i := 0; i < 4; i++ {
+		resp, err := service.Get(&BalancedParams{})
+		assert.Nil(t, err)
+		resp.Body().Close()
+	}
+	assert.False(t, creator.Endpoints()[0].Available())
+	assert.True(t, creator.Endpoints()[1].Available())
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "b.mock.io/host", callHost(t, service, "hexilee"))
+	}
+
+	// back after eject time
+	servers.setDown("a.mock.io", false)
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, creator.Endpoints()[0].Available())
+}
+
+func TestBalancer_IgnoreCanceled(t *testing.T) {
+	servers, client := newReplicas("a.mock.io")
+	servers.setHang("a.mock.io", true)
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		SetOutlierEjection(1, time.Minute).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(ImpatientBalancedService)
+	assert.Nil(t, creator.Impl(service))
+	for i := 0; i < 3; i++ {
+		_, err := service.Get(&BalancedParams{})
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	}
+	assert.True(t, creator.Endpoints()[0].Available())
+}
+
+func TestBalancer_IgnoreRejected(t *testing.T) {
+	servers, client := newReplicas("a.mock.io")
+	servers.setDown("a.mock.io", true)
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		SetOutlierEjection(2, time.Minute).
+		SetCircuitBreaker(gotten.CircuitBreaker{ConsecutiveFailures: 1, OpenTimeout: time.Minute}).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(BalancedService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.Get(&BalancedParams{})
+	assert.Nil(t, err)
+	resp.Body().Close()
+	for i := 0; i < 3; i++ {
+		_, err := service.Get(&BalancedParams{})
+		var openErr *gotten.CircuitOpenError
+		assert.True(t, errors.As(err, &openErr))
+	}
+	assert.True(t, creator.Endpoints()[0].Available())
+
+	// fail fast
+	creator, err = gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		SetOutlierEjection(1, time.Minute).
+		SetRateLimit(1, time.Minute).
+		SetLimitFailFast(true).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+	servers.setDown("a.mock.io", false)
+	assert.Nil(t, creator.Impl(service))
+	assert.Equal(t, "a.mock.io/host", callHost(t, service, "hexilee"))
+	for i := 0; i < 3; i++ {
+		_, err := service.Get(&BalancedParams{})
+		var limitErr *gotten.LimitError
+		assert.True(t, errors.As(err, &limitErr))
+	}
+	assert.True(t, creator.Endpoints()[0].Available())
+}
+
+func TestBalancer_HealthCheck(t *testing.T) {
+	servers, client := newReplicas("a.mock.io", "b.mock.io")
+	servers.setDown("b.mock.io", true)
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		AddEndpoint("https://b.mock.io", 1).
+		SetHealthCheck("/health", 10*time.Millisecond).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+	defer creator.StopHealthCheck()
+
+	assert.Eventually(t, func() bool {
+		return !creator.Endpoints()[1].Available()
+	}, time.Second, 5*time.Millisecond)
+
+	service := new(BalancedService)
+	assert.Nil(t, creator.Impl(service))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "a.mock.io/host", callHost(t, service, "hexilee"))
+	}
+
+	servers.setDown("b.mock.io", false)
+	assert.Eventually(t, func() bool {
+		return creator.Endpoints()[1].Available()
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestBalancer_HealthCheckTimeout(t *testing.T) {
+	servers, client := newReplicas("a.mock.io", "b.mock.io")
+	servers.setHang("a.mock.io", true)
+	creator, err := gotten.NewBuilder().
+		AddEndpoint("https://a.mock.io", 1).
+		AddEndpoint("https://b.mock.io", 1).
+		SetHealthCheck("/health", 10*time.Millisecond).
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+	defer creator.StopHealthCheck()
+
+	// probe of b is not blocked by a
+	assert.Eventually(t, func() bool {
+		return !creator.Endpoints()[0].Available()
+	}, time.Second, 5*time.Millisecond)
+	assert.True(t, creator.Endpoints()[1].Available())
+
+	servers.setHang("a.mock.io", false)
+	assert.Eventually(t, func() bool {
+		return creator.Endpoints()[0].Available()
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,348 @@
+package gotten_test
+
+import (
+	"bytes"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type (
+	StreamService struct {
+		Upload    func(*UploadAvatarParams) (*http.Request, error)   `method:"POST" path:"/avatar"`
+		UploadRaw func(*UploadReaderParams) (*http.Request, error)   `method:"POST" path:"/avatar"`
+		AddPost   func(*AddPostParams) (*http.Request, error)        `method:"POST" path:"/post/{year}/{month}/{day}"`
+		Send      func(*UploadAvatarParams) (gotten.Response, error) `method:"POST" path:"/avatar"`
+		UploadAll func(*UploadFilesParams) (*http.Request, error)    `method:"POST" path:"/avatar"`
+		Sign      func(*OrderedPartsParams) (*http.Request, error)   `method:"POST" path:"/avatar"`
+	}
+
+	OrderedPartsParams struct {
+		Avatar      io.Reader          `type:"part" order:"1"`
+		Uid         int                `type:"part"`
+		Description *AvatarDescription `type:"json"`
+		Username    string             `type:"part"`
+		Signature   string             `type:"part" order:"-1"`
+	}
+
+	UploadFilesParams struct {
+		Files  []gotten.FilePath  `type:"part" key:"files[]" require:"true"`
+		Avatar gotten.FilePart    `type:"part"`
+		Assets []gotten.FSFile    `type:"part"`
+		Extras []*gotten.FilePart `type:"part"`
+	}
+
+	UploadReaderParams struct {
+		Uid    int       `type:"part"`
+		Avatar io.Reader `type:"part"`
+	}
+)
+
+func TestMultipartBody(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(StreamService)
+	assert.Nil(t, creator.Impl(service))
+	params := &UploadAvatarParams{
+		Uid:         1,
+		Username:    "Hexilee",
+		Avatar:      "testAssets/avatar.jpg",
+		Description: &AvatarDescription{"Hexilee", time.Now()},
+	}
+	req, err := service.Upload(params)
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, req.ContentLength, int64(len(data)))
+	info, err := os.Stat("testAssets/avatar.jpg")
+	assert.Nil(t, err)
+	assert.True(t, req.ContentLength > info.Size())
+
+	// rewind
+	assert.NotNil(t, req.GetBody)
+	body, err := req.GetBody()
+	assert.Nil(t, err)
+	rewound, err := ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Equal(t, data, rewound)
+
+	resp, err := service.Send(params)
+	assert.Nil(t, err)
+	var uploaded UploadedData
+	assert.Nil(t, resp.Unmarshal(&uploaded))
+	assert.Equal(t, info.Size(), uploaded.FileSize)
+}
+
+func TestMultipartBody_Reader(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(StreamService)
+	assert.Nil(t, creator.Impl(service))
+
+	// seekable or buffered
+	req, err := service.UploadRaw(&UploadReaderParams{1, strings.NewReader("avatar")})
+	assert.Nil(t, err)
+	assert.True(t, req.ContentLength > 0)
+	assert.NotNil(t, req.GetBody)
+
+	req, err = service.UploadRaw(&UploadReaderParams{1, bytes.NewBufferString("avatar")})
+	assert.Nil(t, err)
+	assert.True(t, req.ContentLength > 0)
+	assert.NotNil(t, req.GetBody)
+
+	// known length but closed after written
+	file, err := os.Open("testAssets/avatar.jpg")
+	assert.Nil(t, err)
+	req, err = service.UploadRaw(&UploadReaderParams{1, file})
+	assert.Nil(t, err)
+	assert.True(t, req.ContentLength > 0)
+	assert.Nil(t, req.GetBody)
+	data, err := ioutil.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, req.ContentLength, int64(len(data)))
+
+	// unknown length
+	req, err = service.UploadRaw(&UploadReaderParams{1, ioutil.NopCloser(strings.NewReader("avatar"))})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), req.ContentLength)
+	assert.Nil(t, req.GetBody)
+	data, err = ioutil.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "avatar")
+}
+
+func TestMultipartBody_ConcurrentRewind(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(StreamService)
+	assert.Nil(t, creator.Impl(service))
+	req, err := service.UploadRaw(&UploadReaderParams{1, strings.NewReader("avatar")})
+	assert.Nil(t, err)
+	assert.NotNil(t, req.GetBody)
+
+	bodies := []io.ReadCloser{req.Body}
+	for i := 0; i < 3; i++ {
+		body, err := req.GetBody()
+		assert.Nil(t, err)
+		bodies = append(bodies, body)
+	}
+
+	results := make(chan []byte, len(bodies))
+	for _, body := range bodies {
+		go func(body io.ReadCloser) {
+			data, _ := ioutil.ReadAll(body)
+			results <- data
+		}(body)
+	}
+	for range bodies {
+		data := <-results
+		assert.Equal(t, req.ContentLength, int64(len(data)))
+		assert.Contains(t, string(data), "avatar")
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (recorder *closeRecorder) Close() error {
+	recorder.closed = true
+	return nil
+}
+
+func TestMultipartBody_Abandoned(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(StreamService)
+	assert.Nil(t, creator.Impl(service))
+
+	// required files are missing
+	avatar := &closeRecorder{Reader: strings.NewReader("avatar")}
+	extra := &closeRecorder{Reader: strings.NewReader("extra")}
+	_, err = service.UploadAll(&UploadFilesParams{
+		Avatar: gotten.FilePart{Name: "avatar.jpg", Reader: avatar},
+		Extras: []*gotten.FilePart{{Name: "extra.txt", Reader: extra}},
+	})
+	assert.NotNil(t, err)
+	assert.True(t, avatar.closed)
+	assert.True(t, extra.closed)
+
+	// body is closed before read
+	avatar = &closeRecorder{Reader: strings.NewReader("avatar")}
+	req, err := service.UploadRaw(&UploadReaderParams{1, avatar})
+	assert.Nil(t, err)
+	assert.False(t, avatar.closed)
+	assert.Nil(t, req.Body.Close())
+	assert.True(t, avatar.closed)
+}
+
+func TestReaderBody(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(StreamService)
+	assert.Nil(t, creator.Impl(service))
+	req, err := service.AddPost(&AddPostParams{2018, 10, 1, &TestPost{"Hexilee", "Stream", "Success!"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(`{"author":"Hexilee","title":"Stream","content":"Success!"}`)), req.ContentLength)
+	assert.NotNil(t, req.GetBody)
+}
+
+func TestMultipartBody_FileParts(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(StreamService)
+	assert.Nil(t, creator.Impl(service))
+
+	_, err = service.UploadAll(&UploadFilesParams{})
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.EmptyRequiredVariableError("Files").Error(), err.Error())
+
+	header := make(http.Header)
+	header.Set("X-Checksum", "none")
+	req, err := service.UploadAll(&UploadFilesParams{
+		Files:  []gotten.FilePath{"testAssets/avatar.jpg", "testAssets/avatar.jpg"},
+		Avatar: gotten.FilePart{Name: "me.png", ContentType: "image/png", Reader: strings.NewReader("png"), Header: header},
+		Assets: []gotten.FSFile{{FS: fstest.MapFS{"dir/a.txt": {Data: []byte("asset")}}, Path: "dir/a.txt"}},
+		Extras: []*gotten.FilePart{nil, {Reader: strings.NewReader("extra")}},
+	})
+	assert.Nil(t, err)
+	assert.True(t, req.ContentLength > 0)
+	assert.NotNil(t, req.GetBody)
+
+	assert.Nil(t, req.ParseMultipartForm(32<<20))
+	files := req.MultipartForm.File
+	assert.Len(t, files["files[]"], 2)
+	assert.Equal(t, "avatar.jpg", files["files[]"][1].Filename)
+	assert.Equal(t, "application/octet-stream", files["files[]"][1].Header.Get("Content-Type"))
+
+	assert.Len(t, files["avatar"], 1)
+	assert.Equal(t, "me.png", files["avatar"][0].Filename)
+	assert.Equal(t, "image/png", files["avatar"][0].Header.Get("Content-Type"))
+	assert.Equal(t, "none", files["avatar"][0].Header.Get("X-Checksum"))
+
+	assert.Len(t, files["assets"], 1)
+	assert.Equal(t, "a.txt", files["assets"][0].Filename)
+	assert.Equal(t, int64(len("asset")), files["assets"][0].Size)
+
+	// part without filename is a value
+	assert.Equal(t, []string{"extra"}, req.MultipartForm.Value["extras"])
+}
+
+func TestMultipartBody_Order(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(StreamService)
+	assert.Nil(t, creator.Impl(service))
+
+	var bodies []string
+	for i := 0; i < 10; i++ {
+		req, err := service.Sign(&OrderedPartsParams{
+			Avatar:      strings.NewReader("avatar"),
+			Uid:         1,
+			Description: &AvatarDescription{"Hexilee", time.Unix(0, 0).UTC()},
+			Username:    "Hexilee",
+			Signature:   "sig",
+		})
+		assert.Nil(t, err)
+		_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		assert.Nil(t, err)
+		reader := multipart.NewReader(req.Body, params["boundary"])
+		var names []string
+		for part, err := reader.NextPart(); err == nil; part, err = reader.NextPart() {
+			names = append(names, part.FormName())
+			if part.FormName() == "description" {
+				assert.Equal(t, headers.MIMEApplicationJavaScriptCharsetUTF8, part.Header.Get(headers.HeaderContentType))
+			}
+		}
+		assert.Equal(t, []string{"signature", "uid", "description", "username", "avatar"}, names)
+
+		body, err := req.GetBody()
+		assert.Nil(t, err)
+		data, err := ioutil.ReadAll(body)
+		assert.Nil(t, err)
+		bodies = append(bodies, strings.ReplaceAll(string(data), params["boundary"], ""))
+	}
+
+	// reproducible except boundary
+	for _, body := range bodies {
+		assert.Equal(t, bodies[0], body)
+	}
+}
+
+func TestMultipartBody_Boundary(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetBoundary("gotten-boundary").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(StreamService)
+	assert.Nil(t, creator.Impl(service))
+
+	var bodies []string
+	for i := 0; i < 3; i++ {
+		req, err := service.UploadRaw(&UploadReaderParams{1, strings.NewReader("avatar")})
+		assert.Nil(t, err)
+		assert.Equal(t, `multipart/form-data; boundary=gotten-boundary`, req.Header.Get(headers.HeaderContentType))
+		data, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		bodies = append(bodies, string(data))
+	}
+
+	// reproducible
+	for _, body := range bodies {
+		assert.Equal(t, bodies[0], body)
+	}
+
+	_, err = gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetBoundary("invalid boundary ").
+		Build()
+	assert.Equal(t, gotten.InvalidBoundaryError("invalid boundary ").Error(), err.Error())
+}
+
+func TestMultipartBody_InvalidOrder(t *testing.T) {
+	var service struct {
+		Upload func(*struct {
+			Avatar io.Reader `type:"part" order:"first"`
+		}) (*http.Request, error) `method:"POST" path:"/avatar"`
+	}
+	creator, err := gotten.NewBuilder().SetBaseUrl("https://mock.io").Build()
+	assert.Nil(t, err)
+	err = creator.Impl(&service)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.InvalidFieldTagError("Avatar", gotten.KeyOrder, "first").Error(), err.Error())
+}
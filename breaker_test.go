@@ -0,0 +1,201 @@
+package gotten_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	breakerOpenTimeout = 50 * time.Millisecond
+	// a probe is in flight for this time
+	breakerProbeDelay = 50 * time.Millisecond
+)
+
+type (
+	BrokenService struct {
+		Get  func(*BrokenParams) (gotten.Response, error) `path:"/post"`
+		Post func(*BrokenParams) (gotten.Response, error) `method:"POST" path:"/post"`
+	}
+
+	BrokenParams struct {
+		Status int `type:"query"`
+	}
+
+	stateRecorder struct {
+		mutex   sync.Mutex
+		changes []string
+	}
+)
+
+// responds status of query
+func respondStatus(w http.ResponseWriter, r *http.Request, _ int) {
+	status := http.StatusOK
+	if raw := r.URL.Query().Get("status"); raw != "" {
+		status, _ = strconv.Atoi(raw)
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(`{"source": "server"}`))
+}
+
+func (recorder *stateRecorder) record(key string, from, to gotten.BreakerState) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.changes = append(recorder.changes, fmt.Sprintf("%s: %s -> %s", key, from, to))
+}
+
+func (recorder *stateRecorder) Changes() []string {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return append([]string(nil), recorder.changes...)
+}
+
+func newBrokenService(t *testing.T, client gotten.Client, breaker gotten.CircuitBreaker) *BrokenService {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(client).
+		SetCircuitBreaker(breaker).
+		Build()
+	assert.Nil(t, err)
+	service := new(BrokenService)
+	assert.Nil(t, creator.Impl(service))
+	return service
+}
+
+func assertCircuitOpen(t *testing.T, err error, key string, state gotten.BreakerState) {
+	var openErr *gotten.CircuitOpenError
+	if assert.True(t, errors.As(err, &openErr)) {
+		assert.Equal(t, key, openErr.Key)
+		assert.Equal(t, state, openErr.State)
+		assert.Equal(t, state == gotten.StateOpen, openErr.RetryAfter > 0)
+	}
+}
+
+func TestCircuitBreaker_ConsecutiveFailures(t *testing.T) {
+	client := newFakeClient(respondStatus)
+	recorder := new(stateRecorder)
+	service := newBrokenService(t, client, gotten.CircuitBreaker{
+		ConsecutiveFailures: 2,
+		OpenTimeout:         breakerOpenTimeout,
+		OnStateChange:       recorder.record,
+	})
+
+	for _, status := range []int{500, 200, 500, 502} {
+		_, err := service.Get(&BrokenParams{status})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, []string{"BrokenService.Get: closed -> open"}, recorder.Changes())
+
+	// rejected immediately, other functions are not affected
+	_, err := service.Get(&BrokenParams{})
+	assertCircuitOpen(t, err, "BrokenService.Get", gotten.StateOpen)
+	assert.Equal(t, 4, client.Requests())
+	_, err = service.Post(&BrokenParams{})
+	assert.Nil(t, err)
+
+	// failed probe opens it again
+	time.Sleep(breakerOpenTimeout)
+	_, err = service.Get(&BrokenParams{500})
+	assert.Nil(t, err)
+	_, err = service.Get(&BrokenParams{})
+	assertCircuitOpen(t, err, "BrokenService.Get", gotten.StateOpen)
+
+	time.Sleep(breakerOpenTimeout)
+	_, err = service.Get(&BrokenParams{})
+	assert.Nil(t, err)
+	_, err = service.Get(&BrokenParams{500})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"BrokenService.Get: closed -> open",
+		"BrokenService.Get: open -> half-open",
+		"BrokenService.Get: half-open -> open",
+		"BrokenService.Get: open -> half-open",
+		"BrokenService.Get: half-open -> closed",
+	}, recorder.Changes())
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	client := newFakeClient(func(w http.ResponseWriter, r *http.Request, n int) {
+		time.Sleep(breakerProbeDelay)
+		respondStatus(w, r, n)
+	})
+	service := newBrokenService(t, client, gotten.CircuitBreaker{
+		ConsecutiveFailures: 1,
+		OpenTimeout:         breakerOpenTimeout,
+		Failure: gotten.CheckerFunc(func(resp *http.Response) bool {
+			return true
+		}),
+	})
+	_, err := service.Get(&BrokenParams{})
+	assert.Nil(t, err)
+	time.Sleep(breakerOpenTimeout)
+
+	// only one probe is in flight
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := service.Get(&BrokenParams{})
+		assert.Nil(t, err)
+	}()
+	time.Sleep(breakerProbeDelay / 2)
+	_, err = service.Get(&BrokenParams{})
+	assertCircuitOpen(t, err, "BrokenService.Get", gotten.StateHalfOpen)
+	<-done
+}
+
+func TestCircuitBreaker_FailureRate(t *testing.T) {
+	client := newFakeClient(respondStatus)
+	service := newBrokenService(t, client, gotten.CircuitBreaker{
+		FailureRate: 0.5,
+		MinRequests: 4,
+		Failure:     new(gotten.CheckerFactory).WhenStatuses(http.StatusTooManyRequests, http.StatusServiceUnavailable).Create(),
+	})
+
+	for _, status := range []int{429, 500, 200, 503} {
+		_, err := service.Get(&BrokenParams{status})
+		assert.Nil(t, err)
+	}
+	_, err := service.Get(&BrokenParams{})
+	assertCircuitOpen(t, err, "BrokenService.Get", gotten.StateOpen)
+	assert.Contains(t, err.Error(), gotten.CircuitOpen+": BrokenService.Get(open), retry after ")
+}
+
+func TestCircuitBreaker_HostAndFallback(t *testing.T) {
+	client := newFakeClient(respondStatus)
+	var fallbackErr *gotten.CircuitOpenError
+	service := newBrokenService(t, client, gotten.CircuitBreaker{
+		By:                  gotten.BreakByHost,
+		ConsecutiveFailures: 1,
+		Fallback: func(req *http.Request, err *gotten.CircuitOpenError) (*http.Response, error) {
+			fallbackErr = err
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{headers.HeaderContentType: {headers.MIMEApplicationJSON}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"source": "fallback"}`)),
+				Request:    req,
+			}, nil
+		},
+	})
+
+	_, err := service.Post(&BrokenParams{500})
+	assert.Nil(t, err)
+
+	resp, err := service.Get(&BrokenParams{})
+	assert.Nil(t, err)
+	var result struct {
+		Source string `json:"source"`
+	}
+	assert.Nil(t, resp.Unmarshal(&result))
+	assert.Equal(t, "fallback", result.Source)
+	assert.Equal(t, "mock.io", fallbackErr.Key)
+	assert.Equal(t, 1, client.Requests())
+}
@@ -0,0 +1,51 @@
+package gotten_test
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type (
+	CharsetService struct {
+		GetJSON func(*struct{}) (gotten.Response, error) `path:"/charset/json"`
+		GetXML  func(*struct{}) (gotten.Response, error) `path:"/charset/xml"`
+		GetHTML func(*struct{}) (gotten.Response, error) `path:"/charset/html"`
+	}
+
+	Greeting struct {
+		Greeting string `json:"greeting" xml:"greeting"`
+	}
+
+	HTMLTitle struct {
+		Title string `html:"title"`
+	}
+)
+
+func TestTranscode(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(CharsetService)
+	assert.Nil(t, creator.Impl(service))
+
+	var greeting Greeting
+	resp, err := service.GetJSON(&struct{}{})
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Unmarshal(&greeting))
+	assert.Equal(t, "你好", greeting.Greeting)
+
+	resp, err = service.GetXML(&struct{}{})
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Unmarshal(&greeting))
+	assert.Equal(t, "こんにちは", greeting.Greeting)
+
+	var title HTMLTitle
+	resp, err = service.GetHTML(&struct{}{})
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Unmarshal(&title))
+	assert.Equal(t, "你好", title.Title)
+}
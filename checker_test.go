@@ -29,3 +29,14 @@ func TestCheckerFactory_Create(t *testing.T) {
 	assert.False(t, new(CheckerFactory).WhenStatuses(http.StatusOK).WhenContentType("text/xml").Create().Check(TestResponse))
 	assert.False(t, new(CheckerFactory).WhenStatuses(http.StatusAccepted).WhenContentType("text/xml").Create().Check(TestResponse))
 }
+
+func TestCheckerFactory_CreateAccept(t *testing.T) {
+	var checker CheckerFunc = new(CheckerFactory).WhenContentType("text/html").Create()
+	assert.True(t, checker(TestResponse))
+
+	accept := new(CheckerFactory).WhenContentType("text/xml", "text/html").CreateAccept()
+	assert.True(t, accept.Check(TestResponse))
+	assert.Equal(t, []string{"text/html", "text/xml"}, accept.Accept())
+	assert.Equal(t, []string{MediaRangeAll}, new(CheckerFactory).CreateAccept().Accept())
+	assert.Empty(t, new(CheckerFactory).WhenStatuses(http.StatusOK).CreateAccept().Accept())
+}
@@ -0,0 +1,11 @@
+// gottenvet checks services of gotten, run it by `go vet -vettool=$(which gottenvet) ./...`
+package main
+
+import (
+	"github.com/Hexilee/gotten/analyzer"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(analyzer.Analyzer)
+}
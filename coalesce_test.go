@@ -0,0 +1,252 @@
+package gotten_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/Hexilee/gotten"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// callers join the first one in this time, before newBlockingClient responds
+	coalesceWindow = 50 * time.Millisecond
+)
+
+type (
+	CoalescedService struct {
+		Get    func(*CoalescedParams) (gotten.Response, error) `path:"/post" coalesce:"Authorization"`
+		Post   func(*CoalescedParams) (gotten.Response, error) `method:"POST" path:"/post" coalesce:""`
+		Single func(*CoalescedParams) (gotten.Response, error) `path:"/post"`
+	}
+
+	ImpatientCoalescedService struct {
+		gotten.Service `timeout:"70ms"`
+		Get            func(*CoalescedParams) (gotten.Response, error) `path:"/post" coalesce:""`
+	}
+
+	CoalescedParams struct {
+		Id    int    `type:"query"`
+		Token string `type:"header" key:"Authorization"`
+		Trace string `type:"header" key:"X-Trace"`
+	}
+)
+
+// responds id of query after delay unless canceled
+func newBlockingClient(delay time.Duration) (client *fakeClient) {
+	client = newFakeClient(func(w http.ResponseWriter, r *http.Request, _ int) {
+		if client.wait(r, delay) {
+			w.Write([]byte(`{"id": "` + r.URL.Query().Get("id") + `"}`))
+		}
+	})
+	return
+}
+
+func callConcurrently(t *testing.T, call func(*CoalescedParams) (gotten.Response, error), params ...*CoalescedParams) {
+	var wg sync.WaitGroup
+	for _, param := range params {
+		wg.Add(1)
+		go func(param *CoalescedParams) {
+			defer wg.Done()
+			resp, err := call(param)
+			assert.Nil(t, err)
+			var result struct {
+				Id string `json:"id"`
+			}
+			assert.Nil(t, resp.Unmarshal(&result))
+			assert.NotEmpty(t, result.Id)
+		}(param)
+	}
+	wg.Wait()
+}
+
+func newCoalescedService(t *testing.T, client gotten.Client) *CoalescedService {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+	service := new(CoalescedService)
+	assert.Nil(t, creator.Impl(service))
+	return service
+}
+
+func TestCoalesce(t *testing.T) {
+	client := newBlockingClient(coalesceWindow)
+	service := newCoalescedService(t, client)
+
+	// unselected headers are ignored
+	callConcurrently(t, service.Get,
+		&CoalescedParams{Id: 1, Token: "a", Trace: "1"},
+		&CoalescedParams{Id: 1, Token: "a", Trace: "2"},
+		&CoalescedParams{Id: 1, Token: "a", Trace: "3"},
+		&CoalescedParams{Id: 1, Token: "a", Trace: "4"},
+	)
+	assert.Equal(t, 1, client.Requests())
+
+	// keyed on url and selected headers
+	client = newBlockingClient(coalesceWindow)
+	service = newCoalescedService(t, client)
+	callConcurrently(t, service.Get,
+		&CoalescedParams{Id: 1, Token: "a"},
+		&CoalescedParams{Id: 1, Token: "b"},
+		&CoalescedParams{Id: 2, Token: "a"},
+		&CoalescedParams{Id: 2, Token: "a"},
+	)
+	assert.Equal(t, 3, client.Requests())
+}
+
+func TestCoalesce_Disabled(t *testing.T) {
+	client := newBlockingClient(coalesceWindow)
+	service := newCoalescedService(t, client)
+
+	// not GET
+	callConcurrently(t, service.Post, &CoalescedParams{Id: 1}, &CoalescedParams{Id: 1})
+	assert.Equal(t, 2, client.Requests())
+
+	client = newBlockingClient(coalesceWindow)
+	service = newCoalescedService(t, client)
+	callConcurrently(t, service.Single, &CoalescedParams{Id: 1}, &CoalescedParams{Id: 1})
+	assert.Equal(t, 2, client.Requests())
+}
+
+func TestCoalesce_IndependentBody(t *testing.T) {
+	client := newBlockingClient(coalesceWindow)
+	service := newCoalescedService(t, client)
+
+	var (
+		wg     sync.WaitGroup
+		bodies = make([][]byte, 3)
+	)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := service.Get(&CoalescedParams{Id: 1})
+			assert.Nil(t, err)
+			resp.Header().Set("X-Index", "changed")
+			bodies[i], err = ioutil.ReadAll(resp.Body())
+			assert.Nil(t, err)
+			assert.Nil(t, resp.Body().Close())
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, client.Requests())
+	for _, body := range bodies {
+		assert.Equal(t, `{"id": "1"}`, string(body))
+	}
+}
+
+func TestCoalesce_EveryCaller(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		traces []string
+		logs   = new(bytes.Buffer)
+	)
+	client := newBlockingClient(coalesceWindow)
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(client).
+		SetHedge(time.Second).
+		SetLogger(slog.New(slog.NewJSONHandler(logs, nil))).
+		SetProgress(func(event gotten.ProgressEvent) {
+			if event.Done {
+				mutex.Lock()
+				traces = append(traces, event.Request.Header.Get("X-Trace"))
+				mutex.Unlock()
+			}
+		}).
+		Build()
+	assert.Nil(t, err)
+	service := new(CoalescedService)
+	assert.Nil(t, creator.Impl(service))
+
+	// every caller is reported and logged with its own request, and gets hedge stats of the upstream request
+	var wg sync.WaitGroup
+	for _, trace := range []string{"1", "2", "3"} {
+		wg.Add(1)
+		go func(trace string) {
+			defer wg.Done()
+			resp, err := service.Get(&CoalescedParams{Id: 1, Trace: trace})
+			assert.Nil(t, err)
+			assert.Nil(t, resp.Unmarshal(&struct{}{}))
+			assert.Equal(t, gotten.HedgeStats{Delay: time.Second, Attempts: 1}, gotten.HedgeStatsOf(resp))
+		}(trace)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, client.Requests())
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, traces)
+	for _, trace := range []string{"1", "2", "3"} {
+		assert.Contains(t, logs.String(), `"X-Trace":["`+trace+`"]`)
+	}
+}
+
+func newImpatientCoalescedService(t *testing.T, client gotten.Client) *ImpatientCoalescedService {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+	service := new(ImpatientCoalescedService)
+	assert.Nil(t, creator.Impl(service))
+	return service
+}
+
+func TestCoalesce_Timeout(t *testing.T) {
+	client := newBlockingClient(100 * time.Millisecond)
+	service := newImpatientCoalescedService(t, client)
+
+	// the first caller times out, the later one still gets the response
+	var leaderErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, leaderErr = service.Get(&CoalescedParams{Id: 1})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	resp, err := service.Get(&CoalescedParams{Id: 1})
+	wg.Wait()
+	assert.True(t, errors.Is(leaderErr, context.DeadlineExceeded))
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Unmarshal(&struct{}{}))
+	assert.Equal(t, 1, client.Requests())
+	assert.Equal(t, 0, client.Canceled())
+
+	// the request is canceled after every caller gives up
+	client = newBlockingClient(time.Second)
+	service = newImpatientCoalescedService(t, client)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Get(&CoalescedParams{Id: 1})
+			assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		}()
+	}
+	wg.Wait()
+	assert.Eventually(t, func() bool {
+		return client.Canceled() == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1, client.Requests())
+}
+
+func TestCoalesce_Panic(t *testing.T) {
+	client := newFakeClient(func(http.ResponseWriter, *http.Request, int) {
+		panic("broken client")
+	})
+	service := newCoalescedService(t, client)
+	for i := 0; i < 2; i++ {
+		_, err := service.Get(&CoalescedParams{Id: 1})
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), gotten.CoalescedCallPanicked)
+		}
+	}
+	assert.Equal(t, 2, client.Requests())
+}
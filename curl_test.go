@@ -0,0 +1,63 @@
+package gotten_test
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestToCurl(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+	var service struct {
+		AddPost func(*AddPostParams) (*http.Request, error) `method:"POST" path:"/post/{year}/{month}/{day}"`
+	}
+	assert.Nil(t, creator.Impl(&service))
+	req, err := service.AddPost(&AddPostParams{2018, 10, 1, &TestPost{"Hexilee", "It's curl", "Success!"}})
+	assert.Nil(t, err)
+
+	cmd, err := gotten.ToCurl(req)
+	assert.Nil(t, err)
+	assert.Equal(t,
+		`curl 'https://mock.io/post/2018/10/1'`+
+			` -H 'Accept: `+gotten.ConditionalUnmarshalers(gotten.DefaultUnmarshalers).Accept()+`'`+
+			` -H 'Content-Type: `+headers.MIMEApplicationJSONCharsetUTF8+`'`+
+			` --data-binary '{"author":"Hexilee","title":"It'\''s curl","content":"Success!"}'`,
+		cmd)
+
+	// body is restored
+	data, err := ioutil.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"author":"Hexilee","title":"It's curl","content":"Success!"}`, string(data))
+
+	req, err = http.NewRequest(http.MethodPut, "https://mock.io/avatar", strings.NewReader("\x00\xff"))
+	assert.Nil(t, err)
+	cmd, err = gotten.ToCurl(req)
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X PUT 'https://mock.io/avatar' --data-binary @<(echo AP8= | base64 -d)`, cmd)
+
+	// curl sends POST if there is data, GET if there is not
+	req, err = http.NewRequest(http.MethodGet, "https://mock.io/search", strings.NewReader(`{"query":"gotten"}`))
+	assert.Nil(t, err)
+	cmd, err = gotten.ToCurl(req)
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X GET 'https://mock.io/search' --data-binary '{"query":"gotten"}'`, cmd)
+
+	req, err = http.NewRequest(http.MethodPost, "https://mock.io/ping", nil)
+	assert.Nil(t, err)
+	cmd, err = gotten.ToCurl(req)
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X POST 'https://mock.io/ping'`, cmd)
+
+	req, err = http.NewRequest(http.MethodGet, "https://mock.io/ping", nil)
+	assert.Nil(t, err)
+	cmd, err = gotten.ToCurl(req)
+	assert.Nil(t, err)
+	assert.Equal(t, `curl 'https://mock.io/ping'`, cmd)
+}
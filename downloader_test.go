@@ -0,0 +1,119 @@
+package gotten_test
+
+import (
+	"crypto/md5"
+	"fmt"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/Hexilee/gotten/unmarshalers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+type (
+	DownloadService struct {
+		GetFile func(*struct{}) (*http.Request, error) `path:"/file"`
+	}
+)
+
+func newDownloadRequest(t *testing.T) *http.Request {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+	service := new(DownloadService)
+	assert.Nil(t, creator.Impl(service))
+	req, err := service.GetFile(nil)
+	assert.Nil(t, err)
+	return req
+}
+
+func takeFileRanges() (ranges []string) {
+	fileRanges.Lock()
+	defer fileRanges.Unlock()
+	ranges, fileRanges.ranges = fileRanges.ranges, nil
+	return
+}
+
+func TestDownloader_Resume(t *testing.T) {
+	data, err := ioutil.ReadFile("testAssets/avatar.jpg")
+	assert.Nil(t, err)
+	hash := fmt.Sprintf("%x", md5.Sum(data))
+
+	dir := t.TempDir()
+	fileCtr, err := new(unmarshalers.FileCtrBuilder).SetBasePath(dir).Build()
+	assert.Nil(t, err)
+	req := newDownloadRequest(t)
+
+	// interrupted download
+	urlHash := md5.Sum([]byte(req.URL.String()))
+	partPath := filepath.Join(dir, fmt.Sprintf(unmarshalers.PartFilePattern, urlHash))
+	validatorPath := filepath.Join(dir, fmt.Sprintf(unmarshalers.ValidatorFilePattern, urlHash))
+	assert.Nil(t, ioutil.WriteFile(partPath, data[:100], 0644))
+	assert.Nil(t, ioutil.WriteFile(validatorPath, []byte(`"avatar"`), 0644))
+
+	takeFileRanges()
+	info, err := unmarshalers.NewDownloader(mockClient, fileCtr).Download(req, hash)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bytes=100-"}, takeFileRanges())
+	assert.Equal(t, "avatar.jpg", info.Filename)
+	assert.Equal(t, hash, info.Hash)
+	assert.Equal(t, int64(len(data)), info.Size)
+	saved, err := ioutil.ReadFile(info.FilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, data, saved)
+
+	// changed file is downloaded again
+	assert.Nil(t, ioutil.WriteFile(partPath, data[:100], 0644))
+	assert.Nil(t, ioutil.WriteFile(validatorPath, []byte(`"changed"`), 0644))
+	info, err = unmarshalers.NewDownloader(mockClient, fileCtr).Download(req, hash)
+	assert.Nil(t, err)
+	assert.Equal(t, hash, info.Hash)
+
+	// mismatch
+	_, err = unmarshalers.NewDownloader(mockClient, fileCtr).Download(req, "wrong")
+	assert.Equal(t, unmarshalers.HashMismatchError("wrong", hash), err)
+	assert.NoFileExists(t, info.FilePath)
+}
+
+func TestDownloader_Parallel(t *testing.T) {
+	data, err := ioutil.ReadFile("testAssets/avatar.jpg")
+	assert.Nil(t, err)
+	hash := fmt.Sprintf("%x", md5.Sum(data))
+
+	fileCtr, err := new(unmarshalers.FileCtrBuilder).SetBasePath(t.TempDir()).Build()
+	assert.Nil(t, err)
+
+	takeFileRanges()
+	info, err := unmarshalers.NewDownloader(mockClient, fileCtr).SetSegments(4).Download(newDownloadRequest(t), hash)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(takeFileRanges())) // probe and 4 segments
+	saved, err := ioutil.ReadFile(info.FilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, data, saved)
+}
+
+func TestDownloader_ParallelEmpty(t *testing.T) {
+	hash := fmt.Sprintf("%x", md5.Sum(nil))
+
+	// empty file cannot satisfy any range
+	client := newFakeClient(func(w http.ResponseWriter, r *http.Request, _ int) {
+		w.Header().Set(headers.HeaderContentDisposition, `attachment; filename="empty.txt"`)
+		w.Header().Set(unmarshalers.HeaderContentRange, "bytes */0")
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	})
+	fileCtr, err := new(unmarshalers.FileCtrBuilder).SetBasePath(t.TempDir()).Build()
+	assert.Nil(t, err)
+
+	info, err := unmarshalers.NewDownloader(client, fileCtr).SetSegments(4).Download(newDownloadRequest(t), hash)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, client.Requests())
+	assert.Equal(t, "empty.txt", info.Filename)
+	assert.Equal(t, int64(0), info.Size)
+	saved, err := ioutil.ReadFile(info.FilePath)
+	assert.Nil(t, err)
+	assert.Empty(t, saved)
+}
@@ -8,8 +8,8 @@ import (
 
 type (
 	SimpleParams struct {
-		Id   int
-		Page int
+		Id   int `type:"path"`
+		Page int `type:"query"`
 	}
 
 	Item struct {
@@ -27,7 +27,7 @@ type (
 	}
 
 	SimpleService struct {
-		GetItems func(SimpleParams) (gotten.Response, error) `method:"GET";path:"itemType/{id}"`
+		GetItems func(*SimpleParams) (gotten.Response, error) `method:"GET" path:"itemType/{id}"`
 	}
 )
 
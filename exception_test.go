@@ -62,48 +62,37 @@ func TestGetReaderFromString(t *testing.T) {
 }
 
 func TestCheckContentType1(t *testing.T) {
-	contentType := headers.MIMEMultipartForm
+	// registered content type is embedded into multipart
 	parser := new(VarsParser)
 	parser.contentType = headers.MIMETextPlain
-	defer func() {
-		err := recover()
-		assert.Equal(t, "Unsupported content type of parser: "+parser.contentType, err)
-	}()
-	parser.checkContentType(contentType)
+	assert.Nil(t, parser.checkContentType(headers.MIMEMultipartForm))
+	assert.Equal(t, headers.MIMEMultipartForm, parser.contentType)
 }
 
 func TestCheckContentType2(t *testing.T) {
+	// registered content type is embedded into form
 	parser := new(VarsParser)
 	parser.contentType = headers.MIMETextPlain
-	contentType := headers.MIMEApplicationForm
-	defer func() {
-		err := recover()
-		assert.Equal(t, "Unsupported content type of parser: "+parser.contentType, err)
-	}()
-
-	parser.checkContentType(contentType)
+	assert.Nil(t, parser.checkContentType(headers.MIMEApplicationForm))
+	assert.Equal(t, headers.MIMEApplicationForm, parser.contentType)
 }
 
 func TestCheckContentType3(t *testing.T) {
 	contentType := headers.MIMEApplicationJSONCharsetUTF8
 	parser := new(VarsParser)
 	parser.contentType = headers.MIMETextPlain
-	defer func() {
-		err := recover()
-		assert.Equal(t, "Unsupported content type of parser: "+parser.contentType, err)
-	}()
-	parser.checkContentType(contentType)
+	assert.Equal(t, ContentTypeConflictError(headers.MIMETextPlain, contentType), parser.checkContentType(contentType))
 }
 
 func TestCheckContentType4(t *testing.T) {
 	contentType := headers.MIMETextPlain
-	defer func() {
-		err := recover()
-		assert.Equal(t, "Unsupported content type: "+contentType, err)
-	}()
 	parser := new(VarsParser)
 	parser.contentType = headers.MIMEApplicationJSONCharsetUTF8
-	parser.checkContentType(contentType)
+	assert.Equal(t, ContentTypeConflictError(headers.MIMEApplicationJSONCharsetUTF8, contentType), parser.checkContentType(contentType))
+
+	parser.contentType = headers.MIMEMultipartForm
+	assert.Nil(t, parser.checkContentType(contentType))
+	assert.Equal(t, headers.MIMEMultipartForm, parser.contentType)
 }
 
 func TestProcessKey(t *testing.T) {
@@ -0,0 +1,90 @@
+package gotten_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/Hexilee/gotten/mock"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// host served by fakeClient
+	fakeHost = "mock.io"
+)
+
+type (
+	// fakeClient sends requests to handler of mock client, it counts requests and records bodies of them
+	fakeClient struct {
+		client   gotten.Client
+		mutex    sync.Mutex
+		requests int
+		canceled int
+		bodies   []string
+	}
+
+	// handler of fakeClient, n is the index of request from 1; content type is json to be unmarshaled
+	fakeHandler func(w http.ResponseWriter, r *http.Request, n int)
+
+	requestIndexKey struct{}
+)
+
+// requests fail with mock.HostNotExistError if handler is nil
+func newFakeClient(handler fakeHandler) *fakeClient {
+	builder := mock.NewClientBuilder()
+	if handler != nil {
+		builder.RegisterFunc(fakeHost, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headers.HeaderContentType, headers.MIMEApplicationJSON)
+			handler(w, r, r.Context().Value(requestIndexKey{}).(int))
+		})
+	}
+	return &fakeClient{client: builder.Build()}
+}
+
+func (client *fakeClient) Do(req *http.Request) (*http.Response, error) {
+	client.mutex.Lock()
+	client.requests++
+	req = req.WithContext(context.WithValue(req.Context(), requestIndexKey{}, client.requests))
+	if req.Body != nil {
+		data, _ := ioutil.ReadAll(req.Body)
+		client.bodies = append(client.bodies, string(data))
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+	client.mutex.Unlock()
+	return client.client.Do(req)
+}
+
+// wait for delay in handler, false if request is canceled before
+func (client *fakeClient) wait(r *http.Request, delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-r.Context().Done():
+		client.mutex.Lock()
+		client.canceled++
+		client.mutex.Unlock()
+		return false
+	}
+}
+
+func (client *fakeClient) Requests() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.requests
+}
+
+func (client *fakeClient) Canceled() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.canceled
+}
+
+func (client *fakeClient) Bodies() []string {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return append([]string(nil), client.bodies...)
+}
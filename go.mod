@@ -1,9 +1,29 @@
 module github.com/Hexilee/gotten
 
+// minimum of golang.org/x/net v0.38.0 and golang.org/x/tools v0.31.0
+go 1.23.0
+
 require (
 	github.com/Hexilee/unhtml v1.1.1
+	github.com/fxamacker/cbor/v2 v2.9.0
 	github.com/go-chi/chi v3.3.3+incompatible
 	github.com/iancoleman/strcase v0.0.0-20180726023541-3605ed457bf7
-	github.com/stretchr/testify v1.2.2
-	golang.org/x/net v0.0.0-20181005035420-146acd28ed58 // indirect
+	github.com/stretchr/testify v1.6.1 // minimum required by github.com/vmihailenco/msgpack/v5
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/net v0.38.0
+	golang.org/x/text v0.23.0
+	golang.org/x/tools v0.31.0
+	google.golang.org/protobuf v1.36.9
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/PuerkitoBio/goquery v1.4.1 // indirect
+	github.com/andybalholm/cascadia v1.0.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/mod v0.24.0 // indirect
+	golang.org/x/sync v0.12.0 // indirect
 )
@@ -0,0 +1,195 @@
+package gotten_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestBuilder_RecordHAR(t *testing.T) {
+	buf := new(bytes.Buffer)
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		RecordHAR(buf).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(SampleService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetPosts(&GetPostsParams{2018, 10, 1, 1, 10})
+	assert.Nil(t, err)
+	var posts []TestPost
+	assert.Nil(t, resp.Unmarshal(&posts))
+	assert.Equal(t, "Hexilee", posts[0].Author)
+
+	resp, err = service.AddPost(&AddPostParams{2018, 10, 2, &TestPost{"Hexilee", "HAR", "Success!"}})
+	assert.Nil(t, err)
+	var added AddedData
+	assert.Nil(t, resp.Unmarshal(&added))
+	assert.True(t, added.Success)
+	assert.Nil(t, creator.CloseHAR())
+
+	var archive struct {
+		Log struct {
+			Version string
+			Entries []gotten.HAREntry
+		}
+	}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &archive))
+	assert.Equal(t, gotten.HARVersion, archive.Log.Version)
+	assert.Equal(t, 2, len(archive.Log.Entries))
+
+	entry := archive.Log.Entries[0]
+	assert.Equal(t, http.MethodGet, entry.Request.Method)
+	assert.Equal(t, "https://mock.io/post/2018/10/1?limit=10&page=1", entry.Request.URL)
+	assert.Equal(t, http.StatusOK, entry.Response.Status)
+	assert.Contains(t, entry.Response.Content.Text, "Hello world!")
+
+	entry = archive.Log.Entries[1]
+	assert.Equal(t, http.MethodPost, entry.Request.Method)
+	assert.Equal(t, `{"author":"Hexilee","title":"HAR","content":"Success!"}`, entry.Request.PostData.Text)
+	assert.Equal(t, http.StatusCreated, entry.Response.Status)
+}
+
+// body fails after partial data
+type brokenBodyClient struct{}
+
+func (brokenBodyClient) Do(*http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{headers.HeaderContentType: {headers.MIMEApplicationJSON}},
+		Body:       ioutil.NopCloser(io.MultiReader(strings.NewReader(`[{"author"`), iotest.ErrReader(io.ErrUnexpectedEOF))),
+	}, nil
+}
+
+func TestBuilder_RecordHAR_Redacted(t *testing.T) {
+	buf := new(bytes.Buffer)
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		RecordHAR(buf).
+		AddHeader("Authorization", "Bearer token").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(LoggedService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetPosts(&LoggedGetParams{2018, 10, 1, 1, "token", "secret"})
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Body().Close())
+
+	stream := new(StreamService)
+	assert.Nil(t, creator.Impl(stream))
+	resp, err = stream.Send(&UploadAvatarParams{
+		Uid:         1,
+		Username:    "Hexilee",
+		Avatar:      "testAssets/avatar.jpg",
+		Description: &AvatarDescription{"Hexilee", time.Now()},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Body().Close())
+	assert.Nil(t, creator.CloseHAR())
+
+	var archive struct {
+		Log struct {
+			Entries []gotten.HAREntry
+		}
+	}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &archive))
+	assert.Equal(t, 2, len(archive.Log.Entries))
+
+	entry := archive.Log.Entries[0]
+	assert.Equal(t, "https://mock.io/post/2018/10/1?page=1&token=%5BREDACTED%5D", entry.Request.URL)
+	assert.NotContains(t, buf.String(), "Bearer token")
+	assert.NotContains(t, buf.String(), "secret")
+	for _, pair := range append(entry.Request.QueryString, entry.Request.Headers...) {
+		switch pair.Name {
+		case "token", "Authorization", "Secret":
+			assert.Equal(t, gotten.RedactedValue, pair.Value)
+		}
+	}
+
+	// binary body is base64
+	entry = archive.Log.Entries[1]
+	assert.Equal(t, "base64", entry.Request.PostData.Encoding)
+	data, err := base64.StdEncoding.DecodeString(entry.Request.PostData.Text)
+	assert.Nil(t, err)
+	assert.Equal(t, entry.Request.BodySize, int64(len(data)))
+}
+
+func TestBuilder_RecordHAR_ReadError(t *testing.T) {
+	buf := new(bytes.Buffer)
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(brokenBodyClient{}).
+		RecordHAR(buf).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(SampleService)
+	assert.Nil(t, creator.Impl(service))
+
+	// the call succeeds, the error is returned after the partial body
+	resp, err := service.GetPosts(&GetPostsParams{2018, 10, 1, 1, 10})
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(resp.Body())
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, `[{"author"`, string(data))
+	assert.Nil(t, creator.CloseHAR())
+
+	var archive struct {
+		Log struct {
+			Entries []gotten.HAREntry
+		}
+	}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &archive))
+	assert.Equal(t, io.ErrUnexpectedEOF.Error(), archive.Log.Entries[0].Comment)
+	assert.Equal(t, `[{"author"`, archive.Log.Entries[0].Response.Content.Text)
+}
+
+func TestBuilder_RecordHAR_Stream(t *testing.T) {
+	buf := new(bytes.Buffer)
+	data := strings.Repeat("a", gotten.HARBodyLimit+10)
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(newFakeClient(func(w http.ResponseWriter, _ *http.Request, _ int) {
+			w.Write([]byte(data))
+		})).
+		RecordHAR(buf).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(SampleService)
+	assert.Nil(t, creator.Impl(service))
+
+	// the entry is recorded after the body is read
+	resp, err := service.GetPosts(&GetPostsParams{2018, 10, 1, 1, 10})
+	assert.Nil(t, err)
+	assert.Zero(t, buf.Len())
+	body, err := ioutil.ReadAll(resp.Body())
+	assert.Nil(t, err)
+	assert.Equal(t, data, string(body))
+	assert.Nil(t, creator.CloseHAR())
+
+	var archive struct {
+		Log struct {
+			Entries []gotten.HAREntry
+		}
+	}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &archive))
+	content := archive.Log.Entries[0].Response.Content
+	assert.Equal(t, int64(len(data)), content.Size)
+	assert.Equal(t, data[:gotten.HARBodyLimit], content.Text)
+	assert.Equal(t, gotten.TruncatedMark, content.Comment)
+}
@@ -27,6 +27,11 @@ const (
 	HeaderXRequestID          = "X-Request-ID"
 	HeaderServer              = "Server"
 	HeaderOrigin              = "Origin"
+	HeaderRetryAfter          = "Retry-After"
+
+	// Rate limit
+	HeaderXRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderXRateLimitReset     = "X-RateLimit-Reset"
 
 	// Access control
 	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
@@ -61,6 +66,7 @@ const (
 	MIMEApplicationPDF                   = "application/pdf"
 	MIMEApplicationForm                  = "application/x-www-form-urlencoded"
 	MIMEApplicationProtobuf              = "application/protobuf"
+	MIMEApplicationXProtobuf             = "application/x-protobuf"
 	MIMEApplicationMsgpack               = "application/msgpack"
 	MIMETextHTML                         = "text/html"
 	MIMETextHTMLCharsetUTF8              = MIMETextHTML + "; " + charsetUTF8
@@ -0,0 +1,158 @@
+package gotten_test
+
+import (
+	"bytes"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/mock"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	// the first attempt of newSlowClient
+	slowResponse = 200 * time.Millisecond
+)
+
+type (
+	HedgedService struct {
+		Get    func(*HedgedParams) (gotten.Response, error)      `path:"/post" hedge:"20ms"`
+		Put    func(*HedgedBodyParams) (gotten.Response, error)  `method:"PUT" path:"/post" hedge:"20ms"`
+		Post   func(*HedgedBodyParams) (gotten.Response, error)  `method:"POST" path:"/post" hedge:"20ms"`
+		Upload func(*HedgedPartsParams) (gotten.Response, error) `method:"PUT" path:"/avatar" hedge:"20ms"`
+		Single func(*HedgedParams) (gotten.Response, error)      `path:"/post" hedge:"0"`
+	}
+
+	HedgedParams struct {
+		Id int `type:"query"`
+	}
+
+	HedgedBodyParams struct {
+		Post *TestPost `type:"json"`
+	}
+
+	HedgedPartsParams struct {
+		Avatar io.Reader `type:"part"`
+	}
+)
+
+// the first attempt responds after slowResponse unless id is 1
+func newSlowClient() (client *fakeClient) {
+	client = newFakeClient(func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 1 && r.URL.Query().Get("id") != "1" && !client.wait(r, slowResponse) {
+			return
+		}
+		w.Write([]byte(`{"attempt": 1}`))
+	})
+	return
+}
+
+func newHedgedService(t *testing.T, client gotten.Client) *HedgedService {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(client).
+		SetHedge(time.Millisecond).
+		Build()
+	assert.Nil(t, err)
+	service := new(HedgedService)
+	assert.Nil(t, creator.Impl(service))
+	return service
+}
+
+func TestHedge(t *testing.T) {
+	client := newSlowClient()
+	service := newHedgedService(t, client)
+	start := time.Now()
+	resp, err := service.Get(&HedgedParams{})
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < slowResponse)
+	assert.Equal(t, gotten.HedgeStats{Delay: 20 * time.Millisecond, Attempts: 2, Winner: 1}, gotten.HedgeStatsOf(resp))
+	assert.Nil(t, resp.Body().Close())
+	assert.Eventually(t, func() bool {
+		return client.Canceled() == 1
+	}, time.Second, time.Millisecond)
+
+	// fast response
+	client = newSlowClient()
+	service = newHedgedService(t, client)
+	resp, err = service.Get(&HedgedParams{1})
+	assert.Nil(t, err)
+	assert.Equal(t, gotten.HedgeStats{Delay: 20 * time.Millisecond, Attempts: 1, Winner: 0}, gotten.HedgeStatsOf(resp))
+	assert.Equal(t, 1, client.Requests())
+}
+
+func TestHedge_Body(t *testing.T) {
+	client := newSlowClient()
+	service := newHedgedService(t, client)
+	resp, err := service.Put(&HedgedBodyParams{&TestPost{"Hexilee", "Hedge", "Success!"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, gotten.HedgeStatsOf(resp).Attempts)
+	bodies := client.Bodies()
+	assert.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1])
+
+	// POST is not idempotent
+	client = newSlowClient()
+	service = newHedgedService(t, client)
+	resp, err = service.Post(&HedgedBodyParams{&TestPost{"Hexilee", "Hedge", "Success!"}})
+	assert.Nil(t, err)
+	assert.Equal(t, gotten.HedgeStats{}, gotten.HedgeStatsOf(resp))
+	assert.Equal(t, 1, client.Requests())
+}
+
+func TestHedge_Parts(t *testing.T) {
+	client := newSlowClient()
+	service := newHedgedService(t, client)
+	resp, err := service.Upload(&HedgedPartsParams{bytes.NewReader([]byte("avatar"))})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, gotten.HedgeStatsOf(resp).Attempts)
+	bodies := client.Bodies()
+	assert.Len(t, bodies, 2)
+	assert.Contains(t, bodies[0], "avatar")
+	assert.Equal(t, bodies[0], bodies[1])
+
+	// reader closed after written is sent once
+	client = newSlowClient()
+	service = newHedgedService(t, client)
+	resp, err = service.Upload(&HedgedPartsParams{ioutil.NopCloser(bytes.NewReader([]byte("avatar")))})
+	assert.Nil(t, err)
+	assert.Equal(t, gotten.HedgeStats{}, gotten.HedgeStatsOf(resp))
+	assert.Equal(t, 1, client.Requests())
+	assert.Contains(t, client.Bodies()[0], "avatar")
+}
+
+func TestHedge_Disabled(t *testing.T) {
+	client := newSlowClient()
+	service := newHedgedService(t, client)
+	start := time.Now()
+	resp, err := service.Single(&HedgedParams{})
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= slowResponse)
+	assert.Equal(t, gotten.HedgeStats{}, gotten.HedgeStatsOf(resp))
+	assert.Equal(t, 1, client.Requests())
+
+	// not implementing HedgedResponse
+	assert.Equal(t, gotten.HedgeStats{}, gotten.HedgeStatsOf(nil))
+}
+
+func TestHedge_Failure(t *testing.T) {
+	client := newFakeClient(nil)
+	service := newHedgedService(t, client)
+	_, err := service.Get(&HedgedParams{})
+	assert.Equal(t, mock.HostNotExistError(fakeHost), err)
+	assert.Equal(t, 1, client.Requests())
+}
+
+func TestInvalidFuncTagError(t *testing.T) {
+	var wrongService struct {
+		Get func(*HedgedParams) (gotten.Response, error) `hedge:"fast"`
+	}
+	creator, err := gotten.NewBuilder().SetBaseUrl("https://mock.io").Build()
+	assert.Nil(t, err)
+	err = creator.Impl(&wrongService)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.InvalidFuncTagError(gotten.KeyHedge, "fast").Error(), err.Error())
+}
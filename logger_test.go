@@ -0,0 +1,128 @@
+package gotten_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/Hexilee/gotten/mock"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type (
+	LoggedService struct {
+		GetPosts      func(*LoggedGetParams) (gotten.Response, error)  `path:"/post/{year}/{month}/{day}"`
+		AddPostByForm func(*LoggedFormParams) (gotten.Response, error) `method:"POST" path:"/post"`
+	}
+
+	LoggedGetParams struct {
+		Year   int    `type:"path"`
+		Month  int    `type:"path"`
+		Day    int    `type:"path"`
+		Page   int    `type:"query"`
+		Token  string `type:"query" sensitive:"true"`
+		Secret string `type:"header" sensitive:"true"`
+	}
+
+	LoggedFormParams struct {
+		Year     int       `type:"form"`
+		Month    int       `type:"form"`
+		Day      int       `type:"form"`
+		Password string    `type:"form" sensitive:"true"`
+		Post     *TestPost `type:"json"`
+	}
+)
+
+func TestBuilder_SetLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		SetLogger(slog.New(slog.NewJSONHandler(buf, nil))).
+		SetLogBodyLimit(40).
+		AddHeader("Authorization", "Bearer token").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(LoggedService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetPosts(&LoggedGetParams{2018, 10, 1, 1, "token", "secret"})
+	assert.Nil(t, err)
+	var posts []TestPost
+	assert.Nil(t, resp.Unmarshal(&posts))
+	assert.Equal(t, "Hexilee", posts[0].Author)
+
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, gotten.LogMessage, record["msg"])
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "GetPosts", record["function"])
+	assert.Equal(t, http.MethodGet, record["method"])
+	assert.Equal(t, "/post/{year}/{month}/{day}", record["path"])
+	assert.Equal(t, "page=1&token=%5BREDACTED%5D", record["query"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	header := record["header"].(map[string]interface{})
+	assert.Equal(t, []interface{}{gotten.RedactedValue}, header["Authorization"])
+	assert.Equal(t, []interface{}{gotten.RedactedValue}, header["Secret"])
+	assert.True(t, strings.HasSuffix(record["response_body"].(string), gotten.TruncatedMark))
+
+	buf.Reset()
+	resp, err = service.AddPostByForm(&LoggedFormParams{
+		Year:     2018,
+		Month:    10,
+		Day:      1,
+		Password: "password",
+		Post:     &TestPost{"Hexilee", "Logged", "Success!"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	var added AddedData
+	assert.Nil(t, resp.Unmarshal(&added))
+	assert.True(t, added.Success)
+
+	record = nil
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	requestBody := record["request_body"].(string)
+	assert.True(t, strings.Contains(requestBody, "password=%5BREDACTED%5D"))
+	assert.False(t, strings.Contains(requestBody, "password=password"))
+}
+
+func TestBuilder_SetLogger_ServerError(t *testing.T) {
+	buf := new(bytes.Buffer)
+	client := mock.NewClientBuilder()
+	client.RegisterFunc("mock.io", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(headers.HeaderContentType, headers.MIMEApplicationJSON)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`"unavailable"`))
+	})
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(client.Build()).
+		SetLogger(slog.New(slog.NewJSONHandler(buf, nil))).
+		SetLogBodyLimit(40).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(LoggedService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetPosts(&LoggedGetParams{Year: 2018, Month: 10, Day: 1})
+	assert.Nil(t, err)
+
+	// logged after body is read
+	assert.Zero(t, buf.Len())
+	data, err := ioutil.ReadAll(resp.Body())
+	assert.Nil(t, err)
+	assert.Equal(t, `"unavailable"`, string(data))
+	assert.Nil(t, resp.Body().Close())
+
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, float64(http.StatusServiceUnavailable), record["status"])
+	assert.Equal(t, `"unavailable"`, record["response_body"])
+}
@@ -0,0 +1,128 @@
+package gotten_test
+
+import (
+	"encoding/json"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	MIMETextCSV = "text/csv"
+)
+
+type (
+	MarshalerService struct {
+		PostCSV       func(*CSVParams) (*http.Request, error)          `method:"POST" path:"/csv"`
+		PostForm      func(*CSVFormParams) (*http.Request, error)      `method:"POST" path:"/csv"`
+		PostMultipart func(*CSVMultipartParams) (*http.Request, error) `method:"POST" path:"/csv"`
+	}
+
+	CSVParams struct {
+		Rows [][]string `type:"csv"`
+	}
+
+	CSVFormParams struct {
+		Id   int        `type:"form"`
+		Rows [][]string `type:"csv"`
+	}
+
+	CSVMultipartParams struct {
+		Id   int        `type:"part"`
+		Rows [][]string `type:"csv" key:"data"`
+	}
+)
+
+func marshalCSV(v interface{}) (data []byte, err error) {
+	rows, _ := v.([][]string)
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, strings.Join(row, ","))
+	}
+	data = []byte(strings.Join(lines, "\n"))
+	return
+}
+
+func TestBuilder_RegisterMarshaler(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		RegisterMarshaler("csv", MIMETextCSV, marshalCSV).
+		Build()
+	assert.Nil(t, err)
+
+	var service MarshalerService
+	assert.Nil(t, creator.Impl(&service))
+	rows := [][]string{{"a", "b"}, {"1", "2"}}
+
+	req, err := service.PostCSV(&CSVParams{rows})
+	assert.Nil(t, err)
+	assert.Equal(t, MIMETextCSV, req.Header.Get(headers.HeaderContentType))
+	data, err := ioutil.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "a,b\n1,2", string(data))
+
+	req, err = service.PostForm(&CSVFormParams{1, rows})
+	assert.Nil(t, err)
+	assert.Equal(t, headers.MIMEApplicationForm, req.Header.Get(headers.HeaderContentType))
+	assert.Nil(t, req.ParseForm())
+	assert.Equal(t, "1", req.PostFormValue("id"))
+	assert.Equal(t, "a,b\n1,2", req.PostFormValue("rows"))
+
+	req, err = service.PostMultipart(&CSVMultipartParams{1, rows})
+	assert.Nil(t, err)
+	assert.Nil(t, req.ParseMultipartForm(1<<20))
+	assert.Equal(t, "1", req.PostFormValue("id"))
+	assert.Equal(t, "a,b\n1,2", req.PostFormValue("data"))
+}
+
+func TestBuilder_RegisterMarshalerOverride(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		RegisterMarshaler(gotten.TypeJSON, headers.MIMEApplicationJSON, func(v interface{}) ([]byte, error) {
+			return json.MarshalIndent(v, "", "  ")
+		}).
+		Build()
+	assert.Nil(t, err)
+
+	var service struct {
+		AddPost func(*AddPostParams) (*http.Request, error) `method:"POST" path:"/post/{year}/{month}/{day}"`
+	}
+	assert.Nil(t, creator.Impl(&service))
+	req, err := service.AddPost(&AddPostParams{2018, 10, 1, &TestPost{"Hexilee", "Indent", "Success!"}})
+	assert.Nil(t, err)
+	assert.Equal(t, headers.MIMEApplicationJSON, req.Header.Get(headers.HeaderContentType))
+	data, err := ioutil.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\n  \"author\": \"Hexilee\",\n  \"title\": \"Indent\",\n  \"content\": \"Success!\"\n}", string(data))
+}
+
+func TestRegisterMarshalerErrors(t *testing.T) {
+	_, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		RegisterMarshaler(gotten.TypeQuery, MIMETextCSV, marshalCSV).
+		Build()
+	assert.Equal(t, gotten.ReservedValueTypeError(gotten.TypeQuery), err)
+
+	_, err = gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		RegisterMarshaler("csv", headers.MIMEMultipartForm, marshalCSV).
+		Build()
+	assert.Equal(t, gotten.ReservedContentTypeError(headers.MIMEMultipartForm), err)
+
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		RegisterMarshaler("csv", MIMETextCSV, marshalCSV).
+		Build()
+	assert.Nil(t, err)
+	var service struct {
+		Post func(*struct {
+			Rows [][]string `type:"csv"`
+			Post *TestPost  `type:"json"`
+		}) (*http.Request, error)
+	}
+	assert.Equal(t, gotten.ContentTypeConflictError(MIMETextCSV, headers.MIMEApplicationJSONCharsetUTF8), creator.Impl(&service))
+}
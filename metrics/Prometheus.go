@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultNamespace = "gotten"
+
+	// content type of prometheus text exposition format
+	ContentTypeText = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// seconds
+	DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// bytes
+	DefaultSizeBuckets = []float64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+)
+
+type (
+	// Prometheus implements gotten.Metrics,
+	// and exposes metrics in prometheus text format as a http.Handler
+	Prometheus struct {
+		namespace      string
+		latencyBuckets []float64
+		sizeBuckets    []float64
+
+		mutex         sync.Mutex
+		requests      map[series]float64
+		inFlight      map[gotten.MetricLabels]float64
+		latencies     map[series]*histogram
+		requestSizes  map[series]*histogram
+		responseSizes map[series]*histogram
+	}
+
+	series struct {
+		labels gotten.MetricLabels
+		status string
+	}
+
+	histogram struct {
+		buckets []float64
+		counts  []uint64
+		sum     float64
+		count   uint64
+	}
+)
+
+func NewPrometheus(namespace string) *Prometheus {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return &Prometheus{
+		namespace:      namespace,
+		latencyBuckets: DefaultLatencyBuckets,
+		sizeBuckets:    DefaultSizeBuckets,
+		requests:       make(map[series]float64),
+		inFlight:       make(map[gotten.MetricLabels]float64),
+		latencies:      make(map[series]*histogram),
+		requestSizes:   make(map[series]*histogram),
+		responseSizes:  make(map[series]*histogram),
+	}
+}
+
+// buckets must be sorted; should be called before any observation
+func (prom *Prometheus) SetLatencyBuckets(buckets ...float64) *Prometheus {
+	prom.latencyBuckets = buckets
+	return prom
+}
+
+// buckets must be sorted; should be called before any observation
+func (prom *Prometheus) SetSizeBuckets(buckets ...float64) *Prometheus {
+	prom.sizeBuckets = buckets
+	return prom
+}
+
+func (prom *Prometheus) InFlight(labels gotten.MetricLabels, delta float64) {
+	prom.mutex.Lock()
+	defer prom.mutex.Unlock()
+	prom.inFlight[labels] += delta
+}
+
+func (prom *Prometheus) ObserveRequest(labels gotten.MetricLabels, status string, latency time.Duration, size int64) {
+	prom.mutex.Lock()
+	defer prom.mutex.Unlock()
+	key := series{labels, status}
+	prom.requests[key]++
+	observe(prom.latencies, key, prom.latencyBuckets, latency.Seconds())
+	if size >= 0 {
+		observe(prom.requestSizes, key, prom.sizeBuckets, float64(size))
+	}
+}
+
+func (prom *Prometheus) ObserveResponseSize(labels gotten.MetricLabels, status string, size int64) {
+	prom.mutex.Lock()
+	defer prom.mutex.Unlock()
+	observe(prom.responseSizes, series{labels, status}, prom.sizeBuckets, float64(size))
+}
+
+func (prom *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(headers.HeaderContentType, ContentTypeText)
+	prom.WriteTo(w)
+}
+
+func (prom *Prometheus) WriteTo(w io.Writer) (n int64, err error) {
+	buf := new(strings.Builder)
+	prom.mutex.Lock()
+	prom.writeCounter(buf, "requests_total", "Total number of requests.", prom.requests)
+	prom.writeGauge(buf, "requests_in_flight", "Number of requests waiting for response.", prom.inFlight)
+	prom.writeHistogram(buf, "request_duration_seconds", "Latency of requests until response headers.", prom.latencies)
+	prom.writeHistogram(buf, "request_size_bytes", "Size of request bodies.", prom.requestSizes)
+	prom.writeHistogram(buf, "response_size_bytes", "Size of response bodies.", prom.responseSizes)
+	prom.mutex.Unlock()
+	var written int
+	written, err = io.WriteString(w, buf.String())
+	n = int64(written)
+	return
+}
+
+func (prom *Prometheus) writeHeader(buf *strings.Builder, name, help, metricType string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func (prom *Prometheus) writeCounter(buf *strings.Builder, name, help string, values map[series]float64) {
+	name = prom.namespace + "_" + name
+	prom.writeHeader(buf, name, help, "counter")
+	for _, key := range sortedSeries(values) {
+		fmt.Fprintf(buf, "%s{%s} %s\n", name, key.format(), formatFloat(values[key]))
+	}
+}
+
+func (prom *Prometheus) writeGauge(buf *strings.Builder, name, help string, values map[gotten.MetricLabels]float64) {
+	name = prom.namespace + "_" + name
+	prom.writeHeader(buf, name, help, "gauge")
+	keys := make([]series, 0, len(values))
+	for labels := range values {
+		keys = append(keys, series{labels: labels})
+	}
+	sortSeries(keys)
+	for _, key := range keys {
+		fmt.Fprintf(buf, "%s{%s} %s\n", name, formatLabels(key.labels), formatFloat(values[key.labels]))
+	}
+}
+
+func (prom *Prometheus) writeHistogram(buf *strings.Builder, name, help string, values map[series]*histogram) {
+	name = prom.namespace + "_" + name
+	prom.writeHeader(buf, name, help, "histogram")
+	for _, key := range sortedSeries(values) {
+		hist := values[key]
+		labels := key.format()
+		var cumulative uint64
+		for i, bound := range hist.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, hist.count)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, formatFloat(hist.sum))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, hist.count)
+	}
+}
+
+func observe(histograms map[series]*histogram, key series, buckets []float64, value float64) {
+	hist, ok := histograms[key]
+	if !ok {
+		hist = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		histograms[key] = hist
+	}
+	// counts are not cumulative here, accumulate them when writing
+	if i := sort.SearchFloat64s(hist.buckets, value); i < len(hist.buckets) {
+		hist.counts[i]++
+	}
+	hist.sum += value
+	hist.count++
+}
+
+func (key series) format() string {
+	return formatLabels(key.labels) + `,status="` + escape(key.status) + `"`
+}
+
+func formatLabels(labels gotten.MetricLabels) string {
+	return fmt.Sprintf(`service="%s",function="%s",method="%s",path="%s"`,
+		escape(labels.Service), escape(labels.Function), escape(labels.Method), escape(labels.Path))
+}
+
+func sortedSeries(values interface{}) (keys []series) {
+	switch m := values.(type) {
+	case map[series]float64:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[series]*histogram:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sortSeries(keys)
+	return
+}
+
+func sortSeries(keys []series) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].format() < keys[j].format()
+	})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
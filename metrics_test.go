@@ -0,0 +1,47 @@
+package gotten_test
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/metrics"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuilder_SetMetrics(t *testing.T) {
+	prom := metrics.NewPrometheus("")
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		SetMetrics(prom).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(SampleService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetPosts(&GetPostsParams{2018, 10, 1, 1, 10})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	var results []TestPost
+	assert.Nil(t, resp.Unmarshal(&results))
+
+	recorder := httptest.NewRecorder()
+	prom.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, metrics.ContentTypeText, recorder.Header().Get("Content-Type"))
+	text := recorder.Body.String()
+	labels := `service="SampleService",function="GetPosts",method="GET",path="/post/{year}/{month}/{day}"`
+	assert.True(t, strings.Contains(text, `gotten_requests_total{`+labels+`,status="2xx"} 1`))
+	assert.True(t, strings.Contains(text, `gotten_requests_in_flight{`+labels+`} 0`))
+	assert.True(t, strings.Contains(text, `gotten_request_duration_seconds_count{`+labels+`,status="2xx"} 1`))
+	assert.True(t, strings.Contains(text, `gotten_request_size_bytes_bucket{`+labels+`,status="2xx",le="64"} 1`))
+	assert.True(t, strings.Contains(text, `gotten_response_size_bytes_count{`+labels+`,status="2xx"} 1`))
+	assert.False(t, strings.Contains(text, "/post/2018"))
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "2xx", gotten.StatusClass(&http.Response{StatusCode: http.StatusCreated}, nil))
+	assert.Equal(t, "4xx", gotten.StatusClass(&http.Response{StatusCode: http.StatusNotFound}, nil))
+	assert.Equal(t, gotten.StatusClassError, gotten.StatusClass(nil, http.ErrHandlerTimeout))
+}
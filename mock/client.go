@@ -41,6 +41,7 @@ func (builder *ClientBuilder) Build() gotten.Client {
 	return &ClientImpl{builder.services}
 }
 
+// error of context is returned if request is canceled while it is handled, like http.Client
 func (client ClientImpl) Do(req *http.Request) (resp *http.Response, err error) {
 	handler, ok := client.services[req.URL.Host]
 	if !ok {
@@ -50,7 +51,9 @@ func (client ClientImpl) Do(req *http.Request) (resp *http.Response, err error)
 	if err == nil {
 		recorder := httptest.NewRecorder()
 		handler.ServeHTTP(recorder, req)
-		resp = recorder.Result()
+		if err = req.Context().Err(); err == nil {
+			resp = recorder.Result()
+		}
 	}
 	return
 }
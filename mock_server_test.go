@@ -1,21 +1,41 @@
 package gotten_test
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"github.com/Hexilee/gotten"
 	"github.com/Hexilee/gotten/headers"
 	"github.com/Hexilee/gotten/mock"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/go-chi/chi"
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"gopkg.in/yaml.v3"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
+	fileRanges struct {
+		sync.Mutex
+		ranges []string
+	}
 	database   *Database
 	router     chi.Router
 	mockClient gotten.Client
@@ -33,6 +53,14 @@ func init() {
 	router.Post("/post/{year}/{month}/{day}", addPost)
 	router.Post("/post", addPostByForm)
 	router.Post("/avatar", addAvatar)
+	router.Post("/echo", echo)
+	router.Get("/posts.{format}", getEncodedPosts)
+	router.Get("/form", getForm)
+	router.Get("/multipart", getMultipart)
+	router.Get("/csv", getCSV)
+	router.Get("/charset/{format}", getEncoded)
+	router.Get("/file", getFile)
+	router.Get("/digest/{header}", getDigestedFile)
 
 	mockBuilder := mock.NewClientBuilder()
 	mockBuilder.Register("mock.io", router)
@@ -45,6 +73,149 @@ func getText(w http.ResponseWriter, _ *http.Request) {
 	w.Write([]byte("true"))
 }
 
+// csv only; respond Accept header as plain text with 406 if csv is not accepted
+func getCSV(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get(headers.HeaderAccept)
+	if !strings.Contains(accept, "text/csv") && !strings.Contains(accept, "*/*") {
+		w.Header().Set(headers.HeaderContentType, headers.MIMETextPlain)
+		w.WriteHeader(http.StatusNotAcceptable)
+		w.Write([]byte(accept))
+		return
+	}
+	w.Header().Set(headers.HeaderContentType, "text/csv")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("uid,username\n1,Hexilee\n"))
+}
+
+// "你好" in json encoded by GBK, xml by Shift_JIS declared in XML declaration,
+// or html by GBK declared in meta tag
+func getEncoded(w http.ResponseWriter, r *http.Request) {
+	var contentType, text string
+	var encoder *encoding.Encoder
+	switch chi.URLParam(r, "format") {
+	case "json":
+		contentType, encoder = headers.MIMEApplicationJSON+"; charset=GBK", simplifiedchinese.GBK.NewEncoder()
+		text = `{"greeting":"你好"}`
+	case "xml":
+		contentType, encoder = headers.MIMEApplicationXML, japanese.ShiftJIS.NewEncoder()
+		text = `<?xml version="1.0" encoding="Shift_JIS"?><message><greeting>こんにちは</greeting></message>`
+	case "html":
+		contentType, encoder = headers.MIMETextHTML, simplifiedchinese.GBK.NewEncoder()
+		text = `<html><head><meta charset="gbk"><title>你好</title></head><body></body></html>`
+	}
+	data, _ := encoder.String(text)
+	w.Header().Set(headers.HeaderContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(data))
+}
+
+// echo body with the same content type
+func echo(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set(headers.HeaderContentType, r.Header.Get(headers.HeaderContentType))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, r.Body)
+}
+
+// posts of 2018-10-1 encoded as yaml, msgpack, cbor or csv
+func getEncodedPosts(w http.ResponseWriter, r *http.Request) {
+	posts := database.Get(2018, 10, 1, 1, 10)
+	var data []byte
+	var contentType string
+	switch chi.URLParam(r, "format") {
+	case "yaml":
+		contentType = "application/yaml; charset=utf-8"
+		data, _ = yaml.Marshal(posts)
+	case "msgpack":
+		contentType = headers.MIMEApplicationMsgpack
+		data, _ = msgpack.Marshal(posts)
+	case "cbor":
+		contentType = "application/cbor"
+		data, _ = cbor.Marshal(posts)
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+		buf := new(bytes.Buffer)
+		writer := csv.NewWriter(buf)
+		writer.Write([]string{"Author", "Title", "Content"})
+		for _, post := range posts {
+			writer.Write([]string{post.Author, post.Title, post.Content})
+		}
+		writer.Flush()
+		data = buf.Bytes()
+	}
+	w.Header().Set(headers.HeaderContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func getForm(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(headers.HeaderContentType, headers.MIMEApplicationForm)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("uid=1&username=Hexilee&tags=go&tags=http"))
+}
+
+// avatar supporting Range and If-Range; ranges of requests are recorded
+func getFile(w http.ResponseWriter, r *http.Request) {
+	file, err := os.Open("testAssets/avatar.jpg")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	fileRanges.Lock()
+	fileRanges.ranges = append(fileRanges.ranges, r.Header.Get("Range"))
+	fileRanges.Unlock()
+	w.Header().Set(headers.HeaderContentDisposition, `attachment; filename="avatar.jpg"`)
+	w.Header().Set("ETag", `"avatar"`)
+	http.ServeContent(w, r, "avatar.jpg", time.Time{}, file)
+}
+
+// avatar with checksum in Content-MD5, Digest or Repr-Digest; checksum of "wrong" mismatches
+func getDigestedFile(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadFile("testAssets/avatar.jpg")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	md5Sum := md5.Sum(data)
+	sha256Sum := sha256.Sum256(data)
+	switch chi.URLParam(r, "header") {
+	case "md5":
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+	case "digest":
+		w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sha256Sum[:]))
+	case "repr-digest":
+		w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sha256Sum[:])+":")
+	case "wrong":
+		w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(md5Sum[:])+":")
+	}
+	w.Header().Set(headers.HeaderContentDisposition, `attachment; filename="avatar.jpg"`)
+	w.Header().Set(headers.HeaderContentType, "image/jpeg")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// a multipart/mixed body of uid, post and avatar
+func getMultipart(w http.ResponseWriter, _ *http.Request) {
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	writer.WriteField("uid", "1")
+	partWriter, _ := writer.CreatePart(textproto.MIMEHeader{
+		headers.HeaderContentType: {headers.MIMEApplicationJSON},
+		"Content-Id":              {"<post>"},
+	})
+	json.NewEncoder(partWriter).Encode(&TestPost{"Hexilee", "Multipart", "Success!"})
+	partWriter, _ = writer.CreateFormFile("avatar", "avatar.jpg")
+	file, _ := os.Open("testAssets/avatar.jpg")
+	io.Copy(partWriter, file)
+	file.Close()
+	writer.Close()
+
+	w.Header().Set(headers.HeaderContentType, "multipart/mixed; boundary="+writer.Boundary())
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
 func getPost(w http.ResponseWriter, r *http.Request) {
 	year, _ := strconv.Atoi(chi.URLParam(r, "year"))
 	month, _ := strconv.Atoi(chi.URLParam(r, "month"))
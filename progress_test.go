@@ -0,0 +1,89 @@
+package gotten_test
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+type (
+	ProgressService struct {
+		Upload func(*ProgressParams) (gotten.Response, error) `method:"POST" path:"/avatar"`
+		Get    func(*GetPostsParams) (gotten.Response, error) `path:"/post/{year}/{month}/{day}"`
+	}
+
+	ProgressParams struct {
+		Uid         int                `type:"part"`
+		Avatar      gotten.FilePath    `type:"part"`
+		Description *AvatarDescription `type:"json"`
+		Progress    gotten.Progress
+	}
+)
+
+func TestProgress(t *testing.T) {
+	var events []gotten.ProgressEvent
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		SetProgress(func(event gotten.ProgressEvent) {
+			events = append(events, event)
+		}).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(ProgressService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.Get(&GetPostsParams{2018, 10, 1, 1, 10})
+	assert.Nil(t, err)
+	var posts []TestPost
+	assert.Nil(t, resp.Unmarshal(&posts))
+	assert.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, gotten.DirectionDownload, last.Direction)
+	assert.True(t, last.Done)
+	assert.True(t, last.Transferred > 0)
+	assert.Equal(t, http.MethodGet, last.Request.Method)
+}
+
+func TestProgress_Params(t *testing.T) {
+	var creatorEvents, uploads, downloads []gotten.ProgressEvent
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		SetProgress(func(event gotten.ProgressEvent) {
+			creatorEvents = append(creatorEvents, event)
+		}).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(ProgressService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.Upload(&ProgressParams{
+		Uid:         1,
+		Avatar:      "testAssets/avatar.jpg",
+		Description: &AvatarDescription{"Hexilee", time.Now()},
+		Progress: func(event gotten.ProgressEvent) {
+			if event.Direction == gotten.DirectionUpload {
+				uploads = append(uploads, event)
+			} else {
+				downloads = append(downloads, event)
+			}
+		},
+	})
+	assert.Nil(t, err)
+	var uploaded UploadedData
+	assert.Nil(t, resp.Unmarshal(&uploaded))
+	assert.Empty(t, creatorEvents)
+
+	info, err := os.Stat("testAssets/avatar.jpg")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, uploads)
+	last := uploads[len(uploads)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, last.Total, last.Transferred)
+	assert.True(t, last.Total > info.Size())
+	assert.True(t, downloads[len(downloads)-1].Done)
+}
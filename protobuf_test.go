@@ -0,0 +1,71 @@
+package gotten_test
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type (
+	ProtobufService struct {
+		Echo        func(*ProtobufParams) (gotten.Response, error)        `method:"POST" path:"/echo"`
+		EchoRequest func(*ProtobufMultipartParams) (*http.Request, error) `method:"POST" path:"/echo"`
+	}
+
+	ProtobufParams struct {
+		Message *wrapperspb.StringValue `type:"protobuf"`
+	}
+
+	ProtobufMultipartParams struct {
+		Id      int                     `type:"part"`
+		Message *wrapperspb.StringValue `type:"protobuf"`
+	}
+)
+
+func TestProtobuf(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(ProtobufService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.Echo(&ProtobufParams{wrapperspb.String(TestString)})
+	assert.Nil(t, err)
+	assert.Equal(t, headers.MIMEApplicationXProtobuf, resp.ContentType())
+	message := new(wrapperspb.StringValue)
+	assert.Nil(t, resp.Unmarshal(message))
+	assert.Equal(t, TestString, message.GetValue())
+
+	resp, err = service.Echo(&ProtobufParams{wrapperspb.String(TestString)})
+	assert.Nil(t, err)
+	var notMessage string
+	assert.Equal(t, gotten.MustPassProtoMessageError(reflect.TypeOf(&notMessage)).Error(), resp.Unmarshal(&notMessage).Error())
+
+	req, err := service.EchoRequest(&ProtobufMultipartParams{1, wrapperspb.String(TestString)})
+	assert.Nil(t, err)
+	assert.Nil(t, req.ParseMultipartForm(1<<20))
+	assert.Equal(t, "1", req.PostFormValue("id"))
+	assert.Nil(t, proto.Unmarshal([]byte(req.PostFormValue("message")), message))
+	assert.Equal(t, TestString, message.GetValue())
+}
+
+func TestProtobufConflict(t *testing.T) {
+	var service struct {
+		Post func(*struct {
+			Json    string                  `type:"json"`
+			Message *wrapperspb.StringValue `type:"protobuf"`
+		}) (*http.Request, error)
+	}
+	creator, err := gotten.NewBuilder().SetBaseUrl("https://mock.io").Build()
+	assert.Nil(t, err)
+	err = creator.Impl(&service)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.ContentTypeConflictError(headers.MIMEApplicationJSONCharsetUTF8, headers.MIMEApplicationXProtobuf).Error(), err.Error())
+}
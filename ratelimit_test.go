@@ -0,0 +1,200 @@
+package gotten_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type (
+	LimitedService struct {
+		gotten.Service `timeout:"100ms"`
+
+		Get       func(*LimitedParams) (gotten.Response, error) `path:"/post" ratelimit:"2/100ms"`
+		Once      func(*LimitedParams) (gotten.Response, error) `path:"/post" ratelimit:"1/h"`
+		Single    func(*LimitedParams) (gotten.Response, error) `path:"/post" concurrency:"1"`
+		Unlimited func(*LimitedParams) (gotten.Response, error) `path:"/post"`
+	}
+
+	LimitedParams struct {
+		Remaining string `type:"query"`
+	}
+)
+
+// responds X-RateLimit-Remaining and X-RateLimit-Reset by query
+func respondRateLimit(w http.ResponseWriter, r *http.Request, _ int) {
+	if remaining := r.URL.Query().Get("remaining"); remaining != "" {
+		w.Header().Set(headers.HeaderXRateLimitRemaining, remaining)
+		w.Header().Set(headers.HeaderXRateLimitReset, "60")
+	}
+	w.Write([]byte(`{}`))
+}
+
+func newLimitedService(t *testing.T, builder *gotten.Builder) *LimitedService {
+	creator, err := builder.
+		SetBaseUrl("https://mock.io").
+		SetClient(newFakeClient(respondRateLimit)).
+		Build()
+	assert.Nil(t, err)
+	service := new(LimitedService)
+	assert.Nil(t, creator.Impl(service))
+	return service
+}
+
+func assertLimitError(t *testing.T, err error, scope, key string, concurrency bool) {
+	var limitErr *gotten.LimitError
+	if assert.True(t, errors.As(err, &limitErr)) {
+		assert.Equal(t, scope, limitErr.Scope)
+		assert.Equal(t, key, limitErr.Key)
+		assert.Equal(t, concurrency, limitErr.Concurrency)
+		assert.Equal(t, concurrency, limitErr.RetryAfter == 0)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for raw, per := range map[string]time.Duration{"10/s": time.Second, "10/m": time.Minute, "10/h": time.Hour, "10/500ms": 500 * time.Millisecond} {
+		count, parsed, err := gotten.ParseRate(raw)
+		assert.Nil(t, err)
+		assert.Equal(t, 10, count)
+		assert.Equal(t, per, parsed)
+	}
+
+	for _, raw := range []string{"10", "0/s", "ten/s", "10/day", "10/-1s", "1/s/s"} {
+		_, _, err := gotten.ParseRate(raw)
+		assert.NotNil(t, err, raw)
+	}
+}
+
+func TestRateLimit_Function(t *testing.T) {
+	service := newLimitedService(t, gotten.NewBuilder())
+
+	// burst of 2, then wait for tokens
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := service.Get(&LimitedParams{})
+		assert.Nil(t, err)
+	}
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+
+	// waiting is canceled by timeout
+	_, err := service.Once(&LimitedParams{})
+	assert.Nil(t, err)
+	_, err = service.Once(&LimitedParams{})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	service = newLimitedService(t, gotten.NewBuilder().SetLimitFailFast(true))
+	_, err = service.Once(&LimitedParams{})
+	assert.Nil(t, err)
+	_, err = service.Once(&LimitedParams{})
+	assertLimitError(t, err, gotten.ScopeFunction, "LimitedService.Once", false)
+	assert.True(t, strings.HasPrefix(err.Error(), gotten.RateLimitExceeded+": function(LimitedService.Once), retry after "))
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	service := newLimitedService(t, gotten.NewBuilder().SetLimitFailFast(true))
+
+	resp, err := service.Single(&LimitedParams{})
+	assert.Nil(t, err)
+	_, err = service.Single(&LimitedParams{})
+	assertLimitError(t, err, gotten.ScopeFunction, "LimitedService.Single", true)
+
+	// in flight until body is closed
+	assert.Nil(t, resp.Body().Close())
+	resp, err = service.Single(&LimitedParams{})
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Unmarshal(&struct{}{}))
+	_, err = service.Single(&LimitedParams{})
+	assert.Nil(t, err)
+}
+
+func TestLimit_HostAndCreator(t *testing.T) {
+	service := newLimitedService(t, gotten.NewBuilder().
+		SetLimitFailFast(true).
+		SetHostConcurrencyLimit("mock.io", 1))
+	resp, err := service.Unlimited(&LimitedParams{})
+	assert.Nil(t, err)
+	_, err = service.Unlimited(&LimitedParams{})
+	assertLimitError(t, err, gotten.ScopeHost, "mock.io", true)
+	assert.Nil(t, resp.Body().Close())
+
+	// other hosts are unlimited
+	service = newLimitedService(t, gotten.NewBuilder().
+		SetLimitFailFast(true).
+		SetHostRateLimit("api.io", 1, time.Hour))
+	for i := 0; i < 3; i++ {
+		_, err = service.Unlimited(&LimitedParams{})
+		assert.Nil(t, err)
+	}
+
+	service = newLimitedService(t, gotten.NewBuilder().
+		SetLimitFailFast(true).
+		SetRateLimit(2, time.Hour).
+		SetConcurrencyLimit(10))
+	for i := 0; i < 2; i++ {
+		_, err = service.Unlimited(&LimitedParams{})
+		assert.Nil(t, err)
+	}
+	_, err = service.Unlimited(&LimitedParams{})
+	assertLimitError(t, err, gotten.ScopeCreator, gotten.ScopeCreator, false)
+}
+
+func TestLimit_Refund(t *testing.T) {
+	service := newLimitedService(t, gotten.NewBuilder().
+		SetLimitFailFast(true).
+		SetHostConcurrencyLimit("mock.io", 1))
+	resp, err := service.Unlimited(&LimitedParams{})
+	assert.Nil(t, err)
+	_, err = service.Once(&LimitedParams{})
+	assertLimitError(t, err, gotten.ScopeHost, "mock.io", true)
+	assert.Nil(t, resp.Body().Close())
+
+	// token of function is given back
+	resp, err = service.Once(&LimitedParams{})
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Body().Close())
+	_, err = service.Once(&LimitedParams{})
+	assertLimitError(t, err, gotten.ScopeFunction, "LimitedService.Once", false)
+}
+
+func TestRateLimit_Adaptive(t *testing.T) {
+	service := newLimitedService(t, gotten.NewBuilder().SetLimitFailFast(true).SetAdaptiveRateLimit(true))
+	_, err := service.Unlimited(&LimitedParams{"1"})
+	assert.Nil(t, err)
+	_, err = service.Unlimited(&LimitedParams{"0"})
+	assert.Nil(t, err)
+
+	// paused until reset
+	_, err = service.Unlimited(&LimitedParams{})
+	assertLimitError(t, err, gotten.ScopeHost, "mock.io", false)
+	var limitErr *gotten.LimitError
+	errors.As(err, &limitErr)
+	assert.True(t, limitErr.RetryAfter > 59*time.Second)
+
+	// ignored if not adaptive
+	service = newLimitedService(t, gotten.NewBuilder().SetLimitFailFast(true))
+	_, err = service.Unlimited(&LimitedParams{"0"})
+	assert.Nil(t, err)
+	_, err = service.Unlimited(&LimitedParams{})
+	assert.Nil(t, err)
+}
+
+func TestRateLimit_InvalidTag(t *testing.T) {
+	var wrongService struct {
+		Get func(*LimitedParams) (gotten.Response, error) `ratelimit:"10"`
+	}
+	creator, err := gotten.NewBuilder().SetBaseUrl("https://mock.io").Build()
+	assert.Nil(t, err)
+	assert.Equal(t, gotten.InvalidFuncTagError(gotten.KeyRateLimit, "10"), creator.Impl(&wrongService))
+	assert.Nil(t, wrongService.Get)
+
+	var wrongConcurrency struct {
+		Get func(*LimitedParams) (gotten.Response, error) `concurrency:"-1"`
+	}
+	assert.Equal(t, gotten.InvalidFuncTagError(gotten.KeyConcurrency, "-1"), creator.Impl(&wrongConcurrency))
+}
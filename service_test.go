@@ -0,0 +1,95 @@
+package gotten_test
+
+import (
+	"context"
+	"github.com/Hexilee/gotten"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type (
+	VersionedService struct {
+		gotten.Service `base:"/v2" headers:"X-Api-Version: 2\nx-client: gotten\nAccept: application/json; charset=utf-8" timeout:"50ms"`
+
+		GetPost  func(*GetPostsParams) (*http.Request, error) `path:"/post/{year}/{month}/{day}"`
+		Override func(*VersionHeaderParams) (*http.Request, error)
+		Wait     func(*VersionHeaderParams) (gotten.Response, error) `path:"/wait"`
+	}
+
+	VersionHeaderParams struct {
+		Version string `type:"header" key:"X-Api-Version"`
+	}
+)
+
+func TestService(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io/api").
+		AddHeader("X-Api-Version", "1").
+		AddHeader("X-Creator", "gotten").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(VersionedService)
+	assert.Nil(t, creator.Impl(service))
+
+	req, err := service.GetPost(&GetPostsParams{Year: 2018, Month: 10, Day: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://mock.io/api/v2/post/2018/10/1?limit=15&page=1", req.URL.String())
+	assert.Equal(t, []string{"2"}, req.Header["X-Api-Version"])
+	assert.Equal(t, "gotten", req.Header.Get("X-Client"))
+	assert.Equal(t, "gotten", req.Header.Get("X-Creator"))
+	assert.Equal(t, []string{"application/json; charset=utf-8"}, req.Header["Accept"])
+
+	// params cover headers of service
+	req, err = service.Override(&VersionHeaderParams{"3"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://mock.io/api/v2", req.URL.String())
+	assert.Equal(t, []string{"3"}, req.Header["X-Api-Version"])
+	_, ok := req.Context().Deadline()
+	assert.False(t, ok)
+}
+
+func TestService_Timeout(t *testing.T) {
+	// blocks until the context is done
+	deadline := make(chan bool, 1)
+	client := newFakeClient(func(_ http.ResponseWriter, r *http.Request, _ int) {
+		_, ok := r.Context().Deadline()
+		deadline <- ok
+		<-r.Context().Done()
+	})
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(client).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(VersionedService)
+	assert.Nil(t, creator.Impl(service))
+
+	start := time.Now()
+	_, err = service.Wait(&VersionHeaderParams{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, <-deadline)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestInvalidServiceTagError(t *testing.T) {
+	creator, err := gotten.NewBuilder().SetBaseUrl("https://mock.io").Build()
+	assert.Nil(t, err)
+
+	var timeoutService struct {
+		gotten.Service `timeout:"5 seconds"`
+	}
+	err = creator.Impl(&timeoutService)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.InvalidServiceTagError(gotten.KeyTimeout, "5 seconds").Error(), err.Error())
+
+	var headersService struct {
+		gotten.Service `headers:"X-Api-Version 2"`
+	}
+	err = creator.Impl(&headersService)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.InvalidServiceTagError(gotten.KeyHeaders, "X-Api-Version 2").Error(), err.Error())
+}
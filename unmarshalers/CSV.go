@@ -0,0 +1,119 @@
+package unmarshalers
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	KeyCSV = "csv"
+)
+
+type (
+	// index of column -> index of struct field
+	csvColumns map[int]int
+)
+
+// UnmarshalCSV decodes csv with header line into v;
+// v can be *[][]string (header line included), *[]map[string]string, *[]struct or *[]*struct;
+// columns are mapped to struct fields by `csv:"name"` tag or field name, case-insensitive
+func UnmarshalCSV(reader io.ReadCloser, _ http.Header, v interface{}) (err error) {
+	defer reader.Close()
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Slice {
+		err = MustPassPtrOfSliceError(reflect.TypeOf(v))
+	}
+
+	var records [][]string
+	if err == nil {
+		records, err = csv.NewReader(reader).ReadAll()
+	}
+
+	if err == nil {
+		switch target := v.(type) {
+		case *[][]string:
+			*target = records
+		case *[]map[string]string:
+			*target = csvMaps(records)
+		default:
+			err = setCSVStructs(value.Elem(), records)
+		}
+	}
+	return
+}
+
+func csvMaps(records [][]string) []map[string]string {
+	maps := make([]map[string]string, 0)
+	if len(records) > 0 {
+		header := records[0]
+		for _, record := range records[1:] {
+			row := make(map[string]string)
+			for i, cell := range record {
+				if i < len(header) {
+					row[header[i]] = cell
+				}
+			}
+			maps = append(maps, row)
+		}
+	}
+	return maps
+}
+
+func setCSVStructs(slice reflect.Value, records [][]string) (err error) {
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+
+	if structType.Kind() != reflect.Struct {
+		return MustPassPtrOfSliceError(reflect.PtrTo(slice.Type()))
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, len(records))
+	if len(records) > 0 {
+		columns := getCSVColumns(structType, records[0])
+		for _, record := range records[1:] {
+			elem := reflect.New(structType)
+			for column, fieldIndex := range columns {
+				if column < len(record) {
+					if err = setText(elem.Elem().Field(fieldIndex), record[column]); err != nil {
+						return
+					}
+				}
+			}
+			if elemType.Kind() != reflect.Ptr {
+				elem = elem.Elem()
+			}
+			result = reflect.Append(result, elem)
+		}
+	}
+	slice.Set(result)
+	return
+}
+
+func getCSVColumns(structType reflect.Type, header []string) csvColumns {
+	names := make(map[string]int)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name := field.Tag.Get(KeyCSV)
+		if field.PkgPath != ZeroStr || name == "-" {
+			continue
+		}
+		if name == ZeroStr {
+			name = field.Name
+		}
+		names[strings.ToLower(name)] = i
+	}
+
+	columns := make(csvColumns)
+	for column, name := range header {
+		if fieldIndex, ok := names[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[column] = fieldIndex
+		}
+	}
+	return columns
+}
@@ -0,0 +1,56 @@
+package unmarshalers
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+)
+
+const (
+	MIMEApplicationYAML     = "application/yaml"
+	MIMEApplicationXYAML    = "application/x-yaml"
+	MIMETextYAML            = "text/yaml"
+	MIMEApplicationXMsgpack = "application/x-msgpack"
+	MIMEApplicationCBOR     = "application/cbor"
+	MIMETextCSV             = "text/csv"
+)
+
+// add them by builder.AddReaderUnmarshaler(unmarshalers.YAML, unmarshalers.YAMLChecker)
+var (
+	YAML        gotten.ReadUnmarshaler = gotten.ReadUnmarshalFunc(UnmarshalYAML)
+	YAMLChecker                        = WhenMediaType(MIMEApplicationYAML, MIMEApplicationXYAML, MIMETextYAML)
+
+	Msgpack        gotten.ReadUnmarshaler = gotten.ReadUnmarshalFunc(UnmarshalMsgpack)
+	MsgpackChecker                        = WhenMediaType(headers.MIMEApplicationMsgpack, MIMEApplicationXMsgpack)
+
+	CBOR        gotten.ReadUnmarshaler = gotten.ReadUnmarshalFunc(UnmarshalCBOR)
+	CBORChecker                        = WhenMediaType(MIMEApplicationCBOR)
+
+	CSV        gotten.ReadUnmarshaler = gotten.ReadUnmarshalFunc(UnmarshalCSV)
+	CSVChecker                        = WhenMediaType(MIMETextCSV)
+)
+
+// WhenMediaType checks the media type of Content-Type, ignoring parameters like charset;
+// the media types are also accepted in generated Accept header
+func WhenMediaType(mediaTypes ...string) gotten.AcceptChecker {
+	return gotten.WhenMediaType(mediaTypes...)
+}
+
+func UnmarshalYAML(reader io.ReadCloser, _ http.Header, v interface{}) error {
+	defer reader.Close()
+	return yaml.NewDecoder(reader).Decode(v)
+}
+
+func UnmarshalMsgpack(reader io.ReadCloser, _ http.Header, v interface{}) error {
+	defer reader.Close()
+	return msgpack.NewDecoder(reader).Decode(v)
+}
+
+func UnmarshalCBOR(reader io.ReadCloser, _ http.Header, v interface{}) error {
+	defer reader.Close()
+	return cbor.NewDecoder(reader).Decode(v)
+}
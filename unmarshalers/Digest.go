@@ -0,0 +1,62 @@
+package unmarshalers
+
+import (
+	"crypto"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const (
+	HeaderContentMD5 = "Content-MD5"
+	HeaderDigest     = "Digest"
+	HeaderReprDigest = "Repr-Digest"
+)
+
+var (
+	// algorithms of Digest (RFC 3230) and Repr-Digest (RFC 9530), in lower case
+	digestAlgorithms = map[string]crypto.Hash{
+		"md5":     crypto.MD5,
+		"sha":     crypto.SHA1,
+		"sha-256": crypto.SHA256,
+		"sha-512": crypto.SHA512,
+	}
+)
+
+// checksums of the whole file in Content-MD5, Digest and Repr-Digest; Repr-Digest takes precedence;
+// Content-MD5 of a partial response is ignored
+func headerDigests(header http.Header) (digests map[crypto.Hash][]byte) {
+	digests = make(map[crypto.Hash][]byte)
+	if value := header.Get(HeaderContentMD5); value != ZeroStr && header.Get(HeaderContentRange) == ZeroStr {
+		if sum, err := base64.StdEncoding.DecodeString(value); err == nil {
+			digests[crypto.MD5] = sum
+		}
+	}
+
+	// SHA-256=base64, MD5=base64
+	for _, value := range header.Values(HeaderDigest) {
+		for _, item := range strings.Split(value, ",") {
+			if pair := strings.SplitN(strings.TrimSpace(item), "=", 2); len(pair) == 2 {
+				addDigest(digests, pair[0], pair[1])
+			}
+		}
+	}
+
+	// sha-256=:base64:, sha-512=:base64:
+	for _, value := range header.Values(HeaderReprDigest) {
+		for _, item := range strings.Split(value, ",") {
+			if pair := strings.SplitN(strings.TrimSpace(item), "=", 2); len(pair) == 2 {
+				addDigest(digests, pair[0], strings.Trim(pair[1], ":"))
+			}
+		}
+	}
+	return
+}
+
+func addDigest(digests map[crypto.Hash][]byte, algorithm, value string) {
+	if hash, ok := digestAlgorithms[strings.ToLower(algorithm)]; ok {
+		if sum, err := base64.StdEncoding.DecodeString(value); err == nil {
+			digests[hash] = sum
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package unmarshalers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"github.com/Hexilee/gotten"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	HeaderRange        = "Range"
+	HeaderIfRange      = "If-Range"
+	HeaderContentRange = "Content-Range"
+	HeaderETag         = "ETag"
+	HeaderLastModified = "Last-Modified"
+
+	PartFilePattern      = "gotten-%x.part"      // md5 of url
+	ValidatorFilePattern = "gotten-%x.validator" // ETag or Last-Modified of the part file
+	HeaderFilePattern    = "gotten-%x.header"    // header of the response the part file starts with
+)
+
+type (
+	// Downloader re-issues request generated by a function like `func(*Params) (*http.Request, error)`;
+	// a download is resumed from the part file in base path of FileCtr, by Range and If-Range
+	Downloader struct {
+		client   gotten.Client
+		ctr      *FileCtr
+		segments int // Default: 1
+	}
+
+	// bytes start-end/total
+	contentRange struct {
+		start, end, total int64
+	}
+)
+
+func NewDownloader(client gotten.Client, ctr *FileCtr) *Downloader {
+	return &Downloader{
+		client:   client,
+		ctr:      ctr,
+		segments: 1,
+	}
+}
+
+// download in n ranged requests in parallel if the server supports, then assemble them;
+// parallel download cannot be resumed
+func (downloader *Downloader) SetSegments(n int) *Downloader {
+	if n > 0 {
+		downloader.segments = n
+	}
+	return downloader
+}
+
+// Download saves the file like FileCtr.Unmarshal;
+// expectedHash is compared with FileInfo.Hash, hex of the first hash set by SetHashes (Default: md5),
+// if it is not empty; the file is removed on mismatch
+func (downloader Downloader) Download(req *http.Request, expectedHash string) (info *FileInfo, err error) {
+	info = new(FileInfo)
+	urlHash := md5.Sum([]byte(req.URL.String()))
+	partPath := filepath.Join(downloader.ctr.basePath, fmt.Sprintf(PartFilePattern, urlHash))
+	validatorPath := filepath.Join(downloader.ctr.basePath, fmt.Sprintf(ValidatorFilePattern, urlHash))
+	headerPath := filepath.Join(downloader.ctr.basePath, fmt.Sprintf(HeaderFilePattern, urlHash))
+
+	var header http.Header
+	if downloader.segments > 1 {
+		header, err = downloader.parallel(req, partPath)
+	} else {
+		header, err = downloader.resume(req, partPath, validatorPath, headerPath)
+	}
+
+	if err == nil {
+		// part file is removed by saveFile on error
+		downloader.remove(validatorPath, headerPath)
+		err = downloader.ctr.saveFile(partPath, header, info)
+	}
+
+	if err == nil && expectedHash != ZeroStr && expectedHash != info.Hash {
+		downloader.ctr.fs.Remove(info.FilePath)
+		err = HashMismatchError(expectedHash, info.Hash)
+	}
+	return
+}
+
+func (downloader Downloader) remove(paths ...string) {
+	for _, path := range paths {
+		downloader.ctr.fs.Remove(path)
+	}
+}
+
+// header of the response the part file starts with is returned;
+// part file is kept if the request or copying fails, so it can be resumed,
+// otherwise it is removed with validator and header files on error
+func (downloader Downloader) resume(req *http.Request, partPath, validatorPath, headerPath string) (header http.Header, err error) {
+	var offset int64
+	var validator []byte
+	var saved http.Header
+	fs := downloader.ctr.fs
+	if stat, statErr := fs.Stat(partPath); statErr == nil {
+		if validator, err = readFile(fs, validatorPath); err == nil {
+			offset = stat.Size()
+			saved, _ = readHeader(fs, headerPath)
+		}
+		err = nil
+	}
+
+	rangeReq := req.Clone(req.Context())
+	if offset > 0 {
+		rangeReq.Header.Set(HeaderRange, fmt.Sprintf("bytes=%d-", offset))
+		rangeReq.Header.Set(HeaderIfRange, string(validator))
+	}
+
+	var resp *http.Response
+	if resp, err = downloader.client.Do(rangeReq); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	// header of 206 or 416 describes the range only
+	header = saved
+	if header == nil {
+		header = resp.Header
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// whole file
+		header = resp.Header
+		flag |= os.O_TRUNC
+	case http.StatusPartialContent:
+		var ranged *contentRange
+		if ranged, err = parseContentRange(resp.Header.Get(HeaderContentRange)); err == nil && ranged.start != offset {
+			err = UnexpectedContentRangeError(resp.Header.Get(HeaderContentRange))
+		}
+		flag |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		// completed already
+		if ranged, rangeErr := parseContentRange(resp.Header.Get(HeaderContentRange)); rangeErr != nil || ranged.total != offset {
+			err = UnexpectedStatusError(resp.StatusCode)
+			downloader.remove(partPath, validatorPath, headerPath)
+		}
+		return
+	default:
+		err = UnexpectedStatusError(resp.StatusCode)
+	}
+
+	if err != nil {
+		downloader.remove(partPath, validatorPath, headerPath)
+		return
+	}
+
+	var file File
+	if file, err = fs.OpenFile(partPath, flag, 0644); err == nil {
+		if resp.StatusCode == http.StatusOK {
+			downloader.remove(validatorPath, headerPath)
+			if validator := responseValidator(resp.Header); validator != ZeroStr {
+				writeFile(fs, validatorPath, []byte(validator))
+				writeHeader(fs, headerPath, resp.Header)
+			}
+		}
+		_, err = io.Copy(file, resp.Body)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
+
+func writeHeader(fs FileSystem, name string, header http.Header) error {
+	var buf bytes.Buffer
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+	return writeFile(fs, name, buf.Bytes())
+}
+
+func readHeader(fs FileSystem, name string) (header http.Header, err error) {
+	var data []byte
+	if data, err = readFile(fs, name); err == nil {
+		var mimeHeader textproto.MIMEHeader
+		if mimeHeader, err = textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader(); err == nil {
+			header = http.Header(mimeHeader)
+		}
+	}
+	return
+}
+
+// probe by the first segment; fall back to a single request if range is not supported
+func (downloader Downloader) parallel(req *http.Request, partPath string) (header http.Header, err error) {
+	fs := downloader.ctr.fs
+	var file File
+	if file, err = fs.OpenFile(partPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return
+	}
+
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fs.Remove(partPath)
+		}
+	}()
+
+	probeReq := req.Clone(req.Context())
+	probeReq.Header.Set(HeaderRange, "bytes=0-0")
+	var resp *http.Response
+	if resp, err = downloader.client.Do(probeReq); err != nil {
+		return
+	}
+	header = resp.Header
+
+	var ranged *contentRange
+	switch resp.StatusCode {
+	case http.StatusOK:
+		_, err = io.Copy(file, resp.Body)
+		resp.Body.Close()
+		return
+	case http.StatusPartialContent:
+		ranged, err = parseContentRange(resp.Header.Get(HeaderContentRange))
+		resp.Body.Close()
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the resource is empty if it is bytes */0
+		resp.Body.Close()
+		if ranged, err = parseContentRange(resp.Header.Get(HeaderContentRange)); err != nil || ranged.total != 0 {
+			err = UnexpectedStatusError(resp.StatusCode)
+		}
+		return
+	default:
+		resp.Body.Close()
+		return header, UnexpectedStatusError(resp.StatusCode)
+	}
+
+	if err == nil {
+		err = file.Truncate(ranged.total)
+	}
+
+	if err == nil {
+		// segments are canceled if one of them fails, the first error is returned
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		segmentReq := req.WithContext(ctx)
+		validator := responseValidator(resp.Header)
+		size := (ranged.total + int64(downloader.segments) - 1) / int64(downloader.segments)
+		var once sync.Once
+		var wg sync.WaitGroup
+		for start := int64(0); start < ranged.total; start += size {
+			end := start + size - 1
+			if end >= ranged.total {
+				end = ranged.total - 1
+			}
+			wg.Add(1)
+			go func(start, end int64) {
+				defer wg.Done()
+				if segmentErr := downloader.segment(segmentReq, file, validator, start, end); segmentErr != nil {
+					once.Do(func() {
+						err = segmentErr
+						cancel()
+					})
+				}
+			}(start, end)
+		}
+		wg.Wait()
+	}
+	return
+}
+
+func (downloader Downloader) segment(req *http.Request, file File, validator string, start, end int64) (err error) {
+	rangeReq := req.Clone(req.Context())
+	rangeReq.Header.Set(HeaderRange, fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator != ZeroStr {
+		rangeReq.Header.Set(HeaderIfRange, validator)
+	}
+
+	var resp *http.Response
+	if resp, err = downloader.client.Do(rangeReq); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return UnexpectedStatusError(resp.StatusCode)
+	}
+
+	var ranged *contentRange
+	if ranged, err = parseContentRange(resp.Header.Get(HeaderContentRange)); err == nil && (ranged.start != start || ranged.end != end) {
+		err = UnexpectedContentRangeError(resp.Header.Get(HeaderContentRange))
+	}
+
+	if err == nil {
+		var written int64
+		written, err = io.Copy(io.NewOffsetWriter(file, start), resp.Body)
+		if err == nil && written != end-start+1 {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	return
+}
+
+// ETag, or Last-Modified if ETag is absent or weak
+func responseValidator(header http.Header) (validator string) {
+	validator = header.Get(HeaderETag)
+	if validator == ZeroStr || strings.HasPrefix(validator, "W/") {
+		validator = header.Get(HeaderLastModified)
+	}
+	return
+}
+
+// bytes start-end/total, or bytes */total
+func parseContentRange(value string) (ranged *contentRange, err error) {
+	ranged = new(contentRange)
+	err = UnexpectedContentRangeError(value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return
+	}
+
+	spec := strings.SplitN(strings.TrimPrefix(value, "bytes "), "/", 2)
+	if len(spec) != 2 {
+		return
+	}
+
+	var parseErr error
+	if ranged.total, parseErr = strconv.ParseInt(spec[1], 10, 64); parseErr != nil {
+		return
+	}
+
+	if spec[0] != "*" {
+		bounds := strings.SplitN(spec[0], "-", 2)
+		if len(bounds) != 2 {
+			return
+		}
+		if ranged.start, parseErr = strconv.ParseInt(bounds[0], 10, 64); parseErr != nil {
+			return
+		}
+		if ranged.end, parseErr = strconv.ParseInt(bounds[1], 10, 64); parseErr != nil {
+			return
+		}
+	}
+	return ranged, nil
+}
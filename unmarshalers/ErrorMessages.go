@@ -1,16 +1,88 @@
 package unmarshalers
 
 import (
+	"crypto"
 	"errors"
 	"fmt"
+	"reflect"
 )
 
 const (
 	ContentDispositionOrFilenameEmpty = "Content-Disposition or filename is empty"
 	UnsupportedFilenameStrategy       = "filename strategy is not supported"
 	MustPassPtrOfFileInfo             = "parameter must be Ptr of FileInfo"
+	MustPassPtrOfSlice                = "parameter must be Ptr of slice of struct, map or []string"
+	UnsupportedFieldType              = "field type is unsupported"
+	MustPassPtrOfStruct               = "parameter must be Ptr of struct"
+	NotMultipart                      = "response is not multipart"
+	NoFileCtrForPart                  = "no FileCtr for file part"
+	NoUnmarshalerFoundForPart         = "no unmarshaler found for part"
+	HashMismatch                      = "hash of file mismatches"
+	UnavailableHash                   = "hash algorithm is unavailable"
+	UnsafeFilePath                    = "file path is outside base path"
+	FileExists                        = "file exists"
+	UnsupportedCollisionPolicy        = "collision policy is not supported"
+	NoAvailableSuffix                 = "no available suffix for file"
+	UnexpectedStatus                  = "unexpected status of ranged download"
+	UnexpectedContentRange            = "unexpected Content-Range"
 )
 
 func UnsupportedFilenameStrategyError(strategy FilenameStrategy) error {
 	return errors.New(fmt.Sprintf(UnsupportedFilenameStrategy+": %d", strategy))
 }
+
+func MustPassPtrOfSliceError(p reflect.Type) error {
+	return errors.New(fmt.Sprintf(MustPassPtrOfSlice+": %v", p))
+}
+
+func UnsupportedFieldTypeError(p reflect.Type) error {
+	return errors.New(UnsupportedFieldType + ": " + p.String())
+}
+
+func MustPassPtrOfStructError(p reflect.Type) error {
+	return errors.New(fmt.Sprintf(MustPassPtrOfStruct+": %v", p))
+}
+
+func NotMultipartError(mediaType string) error {
+	return errors.New(NotMultipart + ": " + mediaType)
+}
+
+func NoFileCtrForPartError(name string) error {
+	return errors.New(NoFileCtrForPart + ": " + name)
+}
+
+func NoUnmarshalerFoundForPartError(contentType string) error {
+	return errors.New(NoUnmarshalerFoundForPart + ": " + contentType)
+}
+
+func HashMismatchError(expected, actual string) error {
+	return errors.New(fmt.Sprintf(HashMismatch+": expected(%s), actual(%s)", expected, actual))
+}
+
+func UnexpectedStatusError(status int) error {
+	return errors.New(fmt.Sprintf(UnexpectedStatus+": %d", status))
+}
+
+func UnexpectedContentRangeError(value string) error {
+	return errors.New(UnexpectedContentRange + ": " + value)
+}
+
+func UnavailableHashError(hash crypto.Hash) error {
+	return errors.New(fmt.Sprintf(UnavailableHash+": %v", hash))
+}
+
+func UnsafeFilePathError(filename string) error {
+	return errors.New(UnsafeFilePath + ": " + filename)
+}
+
+func FileExistsError(filePath string) error {
+	return errors.New(FileExists + ": " + filePath)
+}
+
+func UnsupportedCollisionPolicyError(policy CollisionPolicy) error {
+	return errors.New(fmt.Sprintf(UnsupportedCollisionPolicy+": %d", policy))
+}
+
+func NoAvailableSuffixError(filePath string) error {
+	return errors.New(NoAvailableSuffix + ": " + filePath)
+}
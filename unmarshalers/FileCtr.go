@@ -1,38 +1,58 @@
 package unmarshalers
 
 import (
-	"crypto/md5"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"github.com/Hexilee/gotten/headers"
+	"hash"
 	"io"
-	"io/ioutil"
 	"mime"
 	"net/http"
 	"os"
 	"path"
-	"path/filepath"
 )
 
 type (
 	FilenameStrategy int
+	CollisionPolicy  int
+
+	// Namer names a file after it is hashed, covering FilenameStrategy; the filename is sanitized
+	Namer func(info FileInfo, header http.Header) (filename string, err error)
 
 	FileCtrBuilder struct {
-		basePath      string           // Default: wd
-		extensionName string           // .xxx; order: ContentDisposition > extensionName > ContentType
-		discard       bool             // Default: false
-		strategy      FilenameStrategy // Default: ContentDisposition
+		basePath      string                 // Default: wd
+		extensionName string                 // .xxx; order: ContentDisposition > extensionName > ContentType
+		discard       bool                   // Default: false
+		strategy      FilenameStrategy       // Default: ContentDisposition
+		collision     CollisionPolicy        // Default: Overwrite
+		hashes        []crypto.Hash          // Default: md5
+		verify        bool                   // Default: false
+		expected      map[crypto.Hash][]byte // expected checksums
+		fs            FileSystem             // Default: OSFileSystem
+		namer         Namer
 	}
 
 	FileCtr struct {
-		basePath      string           // Default: wd
-		extensionName string           // .xxx; order: ContentDisposition > extensionName > ContentType
-		discard       bool             // Default: false
-		strategy      FilenameStrategy // Default: ContentDisposition
+		basePath      string                 // Default: wd
+		extensionName string                 // .xxx; order: ContentDisposition > extensionName > ContentType
+		discard       bool                   // Default: false
+		strategy      FilenameStrategy       // Default: ContentDisposition
+		collision     CollisionPolicy        // Default: Overwrite
+		hashes        []crypto.Hash          // Default: md5
+		verify        bool                   // Default: false
+		expected      map[crypto.Hash][]byte // expected checksums
+		fs            FileSystem             // Default: OSFileSystem
+		namer         Namer
 	}
 
 	FileInfo struct {
-		Hash     string
+		Hash     string                 // hex of the first hash algorithm
+		Hashes   map[crypto.Hash]string // hex of all the hash algorithms
 		Filename string
 		FilePath string
 		BasePath string
@@ -48,6 +68,14 @@ const (
 	HashHeader                                 // hash-filename
 )
 
+const (
+	// when file exists
+	Overwrite CollisionPolicy = iota // replace it
+	Skip                             // keep it and discard the new one
+	Suffix                           // save as name-1.ext, name-2.ext, ...
+	Fail                             // return FileExistsError
+)
+
 const (
 	ZeroStr         = ""
 	TempFilePattern = "gotten-*.tmp"
@@ -73,17 +101,87 @@ func (builder *FileCtrBuilder) SetStrategy(strategy FilenameStrategy) *FileCtrBu
 	return builder
 }
 
+// files are written into fs, temp files are created in base path
+func (builder *FileCtrBuilder) SetFileSystem(fs FileSystem) *FileCtrBuilder {
+	builder.fs = fs
+	return builder
+}
+
+func (builder *FileCtrBuilder) SetNamer(namer Namer) *FileCtrBuilder {
+	builder.namer = namer
+	return builder
+}
+
+func (builder *FileCtrBuilder) SetCollisionPolicy(policy CollisionPolicy) *FileCtrBuilder {
+	builder.collision = policy
+	return builder
+}
+
+// the first one is used as FileInfo.Hash and by Hash strategies; like crypto.SHA256, crypto.SHA1, crypto.MD5
+func (builder *FileCtrBuilder) SetHashes(hashes ...crypto.Hash) *FileCtrBuilder {
+	builder.hashes = hashes
+	return builder
+}
+
+// verify file by Content-MD5, Digest or Repr-Digest of response, if the algorithm is set by SetHashes
+func (builder *FileCtrBuilder) Verify() *FileCtrBuilder {
+	builder.verify = true
+	return builder
+}
+
+// verify file by an expected checksum in hex, the algorithm will be computed
+func (builder *FileCtrBuilder) Expect(hash crypto.Hash, checksum string) *FileCtrBuilder {
+	sum, err := hex.DecodeString(checksum)
+	if err != nil {
+		// never match
+		sum = []byte(checksum)
+	}
+	if builder.expected == nil {
+		builder.expected = make(map[crypto.Hash][]byte)
+	}
+	builder.expected[hash] = sum
+	return builder
+}
+
 func (builder *FileCtrBuilder) Build() (ctr *FileCtr, err error) {
 	if builder.basePath == ZeroStr {
 		builder.basePath, err = os.Getwd()
 	}
 
+	fs := builder.fs
+	if fs == nil {
+		fs = OSFileSystem
+	}
+
+	hashes := builder.hashes
+	if len(hashes) == 0 {
+		hashes = []crypto.Hash{crypto.MD5}
+	}
+
+	for hash := range builder.expected {
+		if !containsHash(hashes, hash) {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	for _, hash := range hashes {
+		if err == nil && !hash.Available() {
+			err = UnavailableHashError(hash)
+		}
+	}
+
 	if err == nil {
 		ctr = &FileCtr{
 			basePath:      builder.basePath,
 			extensionName: builder.extensionName,
 			discard:       builder.discard,
 			strategy:      builder.strategy,
+			collision:     builder.collision,
+			hashes:        hashes,
+			verify:        builder.verify,
+			expected:      builder.expected,
+			fs:            fs,
+			namer:         builder.namer,
 		}
 	}
 	return
@@ -94,6 +192,8 @@ func NewFileCtr() (ctr *FileCtr, err error) {
 	dir, err = os.Getwd()
 	ctr = &FileCtr{
 		basePath: dir,
+		hashes:   []crypto.Hash{crypto.MD5},
+		fs:       OSFileSystem,
 	}
 	return
 }
@@ -109,16 +209,16 @@ func (ctr FileCtr) Unmarshal(reader io.ReadCloser, header http.Header, v interfa
 		contentType := header.Get(headers.HeaderContentType)
 		fileInfo.Filename, fileInfo.Ext, err = ctr.filenameInfo(contentDisposition, contentType)
 		if err == nil {
-			if fileInfo.Filename == ZeroStr &&
+			if fileInfo.Filename == ZeroStr && ctr.namer == nil &&
 				(ctr.strategy == ContentDisposition || ctr.strategy == HashHeader) {
 				err = errors.New(ContentDispositionOrFilenameEmpty)
 			}
 
 			if err == nil {
 				if ctr.discard {
-					err = ctr.hashNotSave(reader, fileInfo)
+					err = ctr.hashNotSave(reader, header, fileInfo)
 				} else {
-					err = ctr.hashAndSave(reader, fileInfo)
+					err = ctr.hashAndSave(reader, header, fileInfo)
 				}
 			}
 		}
@@ -127,24 +227,20 @@ func (ctr FileCtr) Unmarshal(reader io.ReadCloser, header http.Header, v interfa
 	return
 }
 
-// 1. if we can parse NOT EMPTY filename, getting ext and return;
+// 1. if we can parse NOT EMPTY filename (filename* takes precedence), sanitizing it, getting ext and return;
 // 2. then, if ctr.extensionName is not empty, ext = ctr.extensionName
 // 3. then, ext = mime.ExtensionsByType(contentType)[0]
 func (ctr FileCtr) filenameInfo(contentDisposition, contentType string) (filename, ext string, err error) {
 	if contentDisposition != ZeroStr {
-		_, params, err := mime.ParseMediaType(contentDisposition)
-		if err == nil {
-			filename = params["filename"]
-			ext = path.Ext(filename)
-		}
+		filename = sanitizeFilename(dispositionFilename(contentDisposition))
+		ext = path.Ext(filename)
 	}
 
-	if err == nil && filename == ZeroStr {
+	if filename == ZeroStr {
 		ext = ctr.extensionName
-		if ext == ZeroStr {
+		if ext == ZeroStr && contentType != ZeroStr {
 			var exts []string
-			exts, err = mime.ExtensionsByType(contentType)
-			if err != nil {
+			if exts, err = mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
 				ext = exts[0]
 			}
 		}
@@ -152,62 +248,170 @@ func (ctr FileCtr) filenameInfo(contentDisposition, contentType string) (filenam
 	return
 }
 
-func (ctr FileCtr) resolveFilePath(info *FileInfo) (err error) {
-	switch ctr.strategy {
-	case ContentDisposition:
-	case Hash:
+func (ctr FileCtr) resolveFilePath(info *FileInfo, header http.Header) (err error) {
+	switch {
+	case ctr.namer != nil:
+		var filename string
+		if filename, err = ctr.namer(*info, header); err == nil {
+			info.Filename = sanitizeFilename(filename)
+		}
+	case ctr.strategy == ContentDisposition:
+	case ctr.strategy == Hash:
 		info.Filename = info.Hash + info.Ext
-	case HashHeader:
+	case ctr.strategy == HashHeader:
 		info.Filename = info.Hash + info.Filename
 	default:
 		err = UnsupportedFilenameStrategyError(ctr.strategy)
 	}
 
 	if err == nil {
-		info.FilePath = filepath.Join(ctr.basePath, info.Filename)
+		info.BasePath = ctr.basePath
+		info.FilePath, err = confine(ctr.basePath, info.Filename)
 	}
 	return
 }
 
-func (ctr FileCtr) hashNotSave(reader io.Reader, info *FileInfo) (err error) {
-	info.Size, info.Hash, err = getSizeAndHash(reader)
+// move file to info.FilePath by collision policy; file is removed if it is not moved
+func (ctr FileCtr) moveFile(filePath string, info *FileInfo) (err error) {
+	if _, statErr := ctr.fs.Stat(info.FilePath); statErr == nil {
+		switch ctr.collision {
+		case Overwrite:
+		case Skip:
+			return ctr.fs.Remove(filePath)
+		case Suffix:
+			var nextPath, filename string
+			if nextPath, filename, err = nextFilePath(ctr.fs, info.FilePath); err != nil {
+				ctr.fs.Remove(filePath)
+				return
+			}
+			info.FilePath, info.Filename = nextPath, filename
+		case Fail:
+			ctr.fs.Remove(filePath)
+			return FileExistsError(info.FilePath)
+		default:
+			ctr.fs.Remove(filePath)
+			return UnsupportedCollisionPolicyError(ctr.collision)
+		}
+	}
+	return ctr.fs.Rename(filePath, info.FilePath)
+}
+
+func (ctr FileCtr) hashNotSave(reader io.Reader, header http.Header, info *FileInfo) (err error) {
+	if err = ctr.getSizeAndHashes(reader, info); err == nil {
+		err = ctr.verifyHashes(header, info)
+	}
+
 	if err == nil {
-		err = ctr.resolveFilePath(info)
+		err = ctr.resolveFilePath(info, header)
 	}
 	return
 }
 
-func (ctr FileCtr) hashAndSave(reader io.Reader, info *FileInfo) (err error) {
-	var tempFile *os.File
-	var tempFilePath string
-	tempFile, tempFilePath, err = newTempFile()
+// file is removed if it cannot be verified
+func (ctr FileCtr) hashAndSave(reader io.Reader, header http.Header, info *FileInfo) (err error) {
+	var tempFile File
+	if tempFile, err = ctr.fs.CreateTemp(ctr.basePath, TempFilePattern); err != nil {
+		return
+	}
+
+	tempFilePath := tempFile.Name()
 	teeReader := io.TeeReader(reader, tempFile)
-	info.Size, info.Hash, err = getSizeAndHash(teeReader)
+	err = ctr.getSizeAndHashes(teeReader, info)
 	tempFile.Close()
 	if err == nil {
-		err = ctr.resolveFilePath(info)
-		if err == nil {
-			err = os.Rename(tempFilePath, info.FilePath)
-		} else {
-			err = os.Remove(tempFilePath)
-		}
+		err = ctr.verifyHashes(header, info)
+	}
+
+	if err == nil {
+		err = ctr.resolveFilePath(info, header)
+	}
+
+	if err == nil {
+		err = ctr.moveFile(tempFilePath, info)
+	} else {
+		ctr.fs.Remove(tempFilePath)
 	}
 	return
 }
 
-func getSizeAndHash(reader io.Reader) (size int64, hash string, err error) {
-	hashWriter := md5.New()
-	size, err = io.Copy(hashWriter, reader)
+// hash a downloaded file and move it into base path like hashAndSave
+func (ctr FileCtr) saveFile(filePath string, header http.Header, info *FileInfo) (err error) {
+	info.Filename, info.Ext, err = ctr.filenameInfo(header.Get(headers.HeaderContentDisposition), header.Get(headers.HeaderContentType))
+	if err == nil && info.Filename == ZeroStr && ctr.namer == nil &&
+		(ctr.strategy == ContentDisposition || ctr.strategy == HashHeader) {
+		err = errors.New(ContentDispositionOrFilenameEmpty)
+	}
+
+	var file File
+	if err == nil {
+		file, err = ctr.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	}
+
+	if err == nil {
+		err = ctr.getSizeAndHashes(file, info)
+		file.Close()
+	}
+
+	if err == nil {
+		err = ctr.verifyHashes(header, info)
+	}
+
+	if err == nil {
+		err = ctr.resolveFilePath(info, header)
+	}
+
 	if err == nil {
-		hash = fmt.Sprintf("%x", hashWriter.Sum(nil))
+		err = ctr.moveFile(filePath, info)
+	} else {
+		ctr.fs.Remove(filePath)
 	}
 	return
 }
 
-func newTempFile() (tempFile *os.File, filePath string, err error) {
-	tempFile, err = ioutil.TempFile(ZeroStr, TempFilePattern)
+func (ctr FileCtr) getSizeAndHashes(reader io.Reader, info *FileInfo) (err error) {
+	hashWriters := make([]hash.Hash, len(ctr.hashes))
+	writers := make([]io.Writer, len(ctr.hashes))
+	for i, algorithm := range ctr.hashes {
+		hashWriters[i] = algorithm.New()
+		writers[i] = hashWriters[i]
+	}
+
+	info.Size, err = io.Copy(io.MultiWriter(writers...), reader)
 	if err == nil {
-		filePath = tempFile.Name()
+		info.Hashes = make(map[crypto.Hash]string)
+		for i, algorithm := range ctr.hashes {
+			info.Hashes[algorithm] = hex.EncodeToString(hashWriters[i].Sum(nil))
+		}
+		info.Hash = info.Hashes[ctr.hashes[0]]
 	}
 	return
 }
+
+// by expected checksums, and Content-MD5, Digest or Repr-Digest if ctr.verify
+func (ctr FileCtr) verifyHashes(header http.Header, info *FileInfo) (err error) {
+	expected := make(map[crypto.Hash][]byte)
+	if ctr.verify {
+		expected = headerDigests(header)
+	}
+
+	for algorithm, sum := range ctr.expected {
+		expected[algorithm] = sum
+	}
+
+	for _, algorithm := range ctr.hashes {
+		if sum, ok := expected[algorithm]; ok && hex.EncodeToString(sum) != info.Hashes[algorithm] {
+			err = HashMismatchError(hex.EncodeToString(sum), info.Hashes[algorithm])
+			break
+		}
+	}
+	return
+}
+
+func containsHash(hashes []crypto.Hash, target crypto.Hash) bool {
+	for _, hash := range hashes {
+		if hash == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,356 @@
+package unmarshalers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// File opened by FileSystem; *os.File is a File
+	File interface {
+		io.Reader
+		io.Writer
+		io.WriterAt
+		io.Closer
+		Name() string
+		Truncate(size int64) error
+	}
+
+	// FileSystem is where FileCtr and Downloader save files
+	FileSystem interface {
+		OpenFile(name string, flag int, perm os.FileMode) (File, error)
+		// create a new file in dir, "*" in pattern is replaced by a random string
+		CreateTemp(dir, pattern string) (File, error)
+		Rename(oldPath, newPath string) error
+		Remove(name string) error
+		Stat(name string) (os.FileInfo, error)
+	}
+
+	osFileSystem struct{}
+
+	// MemFileSystem keeps files in memory, keyed by cleaned path
+	MemFileSystem struct {
+		mutex sync.RWMutex
+		files map[string]*memData
+		seq   int
+	}
+
+	memData struct {
+		mutex   sync.RWMutex
+		data    []byte
+		modTime time.Time
+	}
+
+	memFile struct {
+		name   string
+		data   *memData
+		offset int64
+		append bool
+	}
+
+	memFileInfo struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+
+	// every file is written into the writer, no one can be read, renamed or removed
+	writerSink struct {
+		mutex  sync.Mutex
+		writer io.Writer
+	}
+
+	sinkFile struct {
+		name string
+		sink *writerSink
+	}
+)
+
+var (
+	// OSFileSystem is the default FileSystem
+	OSFileSystem FileSystem = osFileSystem{}
+
+	ErrSinkNotReadable = errors.New("writer sink cannot be read")
+)
+
+func (osFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFileSystem) CreateTemp(dir, pattern string) (File, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+func (osFileSystem) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func NewMemFileSystem() *MemFileSystem {
+	return &MemFileSystem{files: make(map[string]*memData)}
+}
+
+// ReadFile returns a copy of content of the file
+func (fs *MemFileSystem) ReadFile(name string) (data []byte, err error) {
+	fs.mutex.RLock()
+	file, ok := fs.files[filepath.Clean(name)]
+	fs.mutex.RUnlock()
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	file.mutex.RLock()
+	defer file.mutex.RUnlock()
+	return append([]byte(nil), file.data...), nil
+}
+
+// Names returns sorted paths of all the files
+func (fs *MemFileSystem) Names() (names []string) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	for name := range fs.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func (fs *MemFileSystem) OpenFile(name string, flag int, _ os.FileMode) (File, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	name = filepath.Clean(name)
+	data, ok := fs.files[name]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case !ok:
+		data = &memData{modTime: time.Now()}
+		fs.files[name] = data
+	case flag&os.O_TRUNC != 0:
+		data.mutex.Lock()
+		data.data = data.data[:0]
+		data.mutex.Unlock()
+	}
+	return &memFile{name: name, data: data, append: flag&os.O_APPEND != 0}, nil
+}
+
+func (fs *MemFileSystem) CreateTemp(dir, pattern string) (file File, err error) {
+	for {
+		fs.mutex.Lock()
+		fs.seq++
+		seq := fs.seq
+		fs.mutex.Unlock()
+		name := filepath.Join(dir, strings.Replace(pattern, "*", strconv.Itoa(seq), 1))
+		if file, err = fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600); !os.IsExist(err) {
+			return
+		}
+	}
+}
+
+func (fs *MemFileSystem) Rename(oldPath, newPath string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	data, ok := fs.files[filepath.Clean(oldPath)]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrNotExist}
+	}
+	delete(fs.files, filepath.Clean(oldPath))
+	fs.files[filepath.Clean(newPath)] = data
+	return nil
+}
+
+func (fs *MemFileSystem) Remove(name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if _, ok := fs.files[filepath.Clean(name)]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(fs.files, filepath.Clean(name))
+	return nil
+}
+
+func (fs *MemFileSystem) Stat(name string) (os.FileInfo, error) {
+	fs.mutex.RLock()
+	data, ok := fs.files[filepath.Clean(name)]
+	fs.mutex.RUnlock()
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	data.mutex.RLock()
+	defer data.mutex.RUnlock()
+	return &memFileInfo{filepath.Base(name), int64(len(data.data)), data.modTime}, nil
+}
+
+func (file *memFile) Name() string {
+	return file.name
+}
+
+func (file *memFile) Read(p []byte) (n int, err error) {
+	file.data.mutex.RLock()
+	defer file.data.mutex.RUnlock()
+	if file.offset >= int64(len(file.data.data)) {
+		return 0, io.EOF
+	}
+	n = copy(p, file.data.data[file.offset:])
+	file.offset += int64(n)
+	return
+}
+
+// offset of appending file is the end of data when it is written, under the same lock
+func (file *memFile) Write(p []byte) (n int, err error) {
+	file.data.mutex.Lock()
+	defer file.data.mutex.Unlock()
+	if file.append {
+		file.offset = int64(len(file.data.data))
+	}
+	n, err = file.writeAt(p, file.offset)
+	file.offset += int64(n)
+	return
+}
+
+func (file *memFile) WriteAt(p []byte, offset int64) (n int, err error) {
+	file.data.mutex.Lock()
+	defer file.data.mutex.Unlock()
+	return file.writeAt(p, offset)
+}
+
+// must be called with mutex of data locked
+func (file *memFile) writeAt(p []byte, offset int64) (n int, err error) {
+	if end := offset + int64(len(p)); end > int64(len(file.data.data)) {
+		file.data.data = append(file.data.data, make([]byte, end-int64(len(file.data.data)))...)
+	}
+	n = copy(file.data.data[offset:], p)
+	file.data.modTime = time.Now()
+	return
+}
+
+func (file *memFile) Truncate(size int64) error {
+	file.data.mutex.Lock()
+	defer file.data.mutex.Unlock()
+	if size <= int64(len(file.data.data)) {
+		file.data.data = file.data.data[:size]
+	} else {
+		file.data.data = append(file.data.data, make([]byte, size-int64(len(file.data.data)))...)
+	}
+	return nil
+}
+
+func (file *memFile) Close() error {
+	return nil
+}
+
+func (info *memFileInfo) Name() string {
+	return info.name
+}
+
+func (info *memFileInfo) Size() int64 {
+	return info.size
+}
+
+func (info *memFileInfo) Mode() os.FileMode {
+	return 0644
+}
+
+func (info *memFileInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info *memFileInfo) IsDir() bool {
+	return false
+}
+
+func (info *memFileInfo) Sys() interface{} {
+	return nil
+}
+
+// NewWriterSink streams every saved file into writer in sequence;
+// files cannot be removed on verification failure, and Downloader is not supported
+func NewWriterSink(writer io.Writer) FileSystem {
+	return &writerSink{writer: writer}
+}
+
+func (sink *writerSink) OpenFile(name string, _ int, _ os.FileMode) (File, error) {
+	return nil, &os.PathError{Op: "open", Path: name, Err: ErrSinkNotReadable}
+}
+
+func (sink *writerSink) CreateTemp(dir, pattern string) (File, error) {
+	return &sinkFile{filepath.Join(dir, pattern), sink}, nil
+}
+
+func (sink *writerSink) Rename(_, _ string) error {
+	return nil
+}
+
+func (sink *writerSink) Remove(_ string) error {
+	return nil
+}
+
+// nothing exists
+func (sink *writerSink) Stat(name string) (os.FileInfo, error) {
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (file *sinkFile) Name() string {
+	return file.name
+}
+
+func (file *sinkFile) Read(_ []byte) (int, error) {
+	return 0, ErrSinkNotReadable
+}
+
+func (file *sinkFile) Write(p []byte) (int, error) {
+	file.sink.mutex.Lock()
+	defer file.sink.mutex.Unlock()
+	return file.sink.writer.Write(p)
+}
+
+func (file *sinkFile) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, ErrSinkNotReadable
+}
+
+func (file *sinkFile) Truncate(_ int64) error {
+	return ErrSinkNotReadable
+}
+
+func (file *sinkFile) Close() error {
+	return nil
+}
+
+// read all the file
+func readFile(fs FileSystem, name string) (data []byte, err error) {
+	var file File
+	if file, err = fs.OpenFile(name, os.O_RDONLY, 0); err == nil {
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(file)
+		file.Close()
+		data = buf.Bytes()
+	}
+	return
+}
+
+func writeFile(fs FileSystem, name string, data []byte) (err error) {
+	var file File
+	if file, err = fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err == nil {
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
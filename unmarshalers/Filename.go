@@ -0,0 +1,115 @@
+package unmarshalers
+
+import (
+	"fmt"
+	"golang.org/x/net/html/charset"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const (
+	ParamFilename         = "filename"
+	ParamFilenameExtended = "filename*"
+	SuffixPattern         = "%s-%d%s" // name-1.ext
+	MaxSuffix             = 10000     // name-1.ext to name-10000.ext are tried
+)
+
+var (
+	// reserved by windows or shells
+	unsafeFilenameReplacer = strings.NewReplacer(
+		"<", "_", ">", "_", ":", "_", `"`, "_", "|", "_", "?", "_", "*", "_",
+	)
+)
+
+// filename of Content-Disposition; filename* (RFC 5987) takes precedence,
+// charset of filename* other than UTF-8 is also supported
+func dispositionFilename(contentDisposition string) (filename string) {
+	_, params, err := mime.ParseMediaType(contentDisposition)
+	if err == nil {
+		filename = params[ParamFilename]
+	}
+
+	// mime.ParseMediaType only decodes filename* in UTF-8 or US-ASCII
+	for _, param := range strings.Split(contentDisposition, ";") {
+		pair := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(pair) == 2 && strings.EqualFold(strings.TrimSpace(pair[0]), ParamFilenameExtended) {
+			if decoded, ok := decodeExtValue(strings.TrimSpace(pair[1])); ok {
+				filename = decoded
+			}
+		}
+	}
+	return
+}
+
+// charset'language'percent-encoded
+func decodeExtValue(value string) (decoded string, ok bool) {
+	parts := strings.SplitN(value, "'", 3)
+	if len(parts) != 3 {
+		return
+	}
+
+	unescaped, err := url.PathUnescape(parts[2])
+	if err != nil {
+		return
+	}
+
+	label := strings.ToLower(parts[0])
+	if label == "utf-8" || label == "us-ascii" {
+		return unescaped, true
+	}
+
+	reader, err := charset.NewReaderLabel(label, strings.NewReader(unescaped))
+	if err == nil {
+		var data []byte
+		if data, err = ioutil.ReadAll(reader); err == nil {
+			decoded, ok = string(data), true
+		}
+	}
+	return
+}
+
+// base name without path separators, control and reserved characters; empty if nothing remains
+func sanitizeFilename(filename string) string {
+	filename = strings.Replace(filename, `\`, "/", -1)
+	filename = filename[strings.LastIndex(filename, "/")+1:]
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filename)
+	filename = unsafeFilenameReplacer.Replace(filename)
+	filename = strings.Trim(filename, " .")
+	return filename
+}
+
+// join filename onto basePath, the result must be inside basePath
+func confine(basePath, filename string) (filePath string, err error) {
+	filePath = filepath.Join(basePath, filename)
+	rel, relErr := filepath.Rel(basePath, filePath)
+	if filename == ZeroStr || relErr != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		err = UnsafeFilePathError(filename)
+	}
+	return
+}
+
+// name-1.ext, name-2.ext, ... which does not exist, up to MaxSuffix;
+// error of Stat other than not existing is returned
+func nextFilePath(fs FileSystem, filePath string) (nextPath, filename string, err error) {
+	ext := filepath.Ext(filePath)
+	name := strings.TrimSuffix(filePath, ext)
+	for i := 1; i <= MaxSuffix; i++ {
+		nextPath = fmt.Sprintf(SuffixPattern, name, i, ext)
+		if _, err = fs.Stat(nextPath); os.IsNotExist(err) {
+			return nextPath, filepath.Base(nextPath), nil
+		} else if err != nil {
+			return
+		}
+	}
+	return ZeroStr, ZeroStr, NoAvailableSuffixError(filePath)
+}
@@ -0,0 +1,60 @@
+package unmarshalers
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+var (
+	Form        gotten.ReadUnmarshaler = gotten.ReadUnmarshalFunc(UnmarshalForm)
+	FormChecker                        = WhenMediaType(headers.MIMEApplicationForm)
+)
+
+// UnmarshalForm decodes form-urlencoded body into v;
+// v can be *url.Values, *map[string]string or ptr of struct,
+// fields of struct are keyed by `key` tag or snake case of field name, like TypeForm params
+func UnmarshalForm(reader io.ReadCloser, _ http.Header, v interface{}) (err error) {
+	defer reader.Close()
+	var data []byte
+	var values url.Values
+	if data, err = ioutil.ReadAll(reader); err == nil {
+		values, err = url.ParseQuery(string(data))
+	}
+
+	if err == nil {
+		switch target := v.(type) {
+		case *url.Values:
+			*target = values
+		case *map[string]string:
+			*target = make(map[string]string)
+			for key := range values {
+				(*target)[key] = values.Get(key)
+			}
+		default:
+			err = setFormStruct(v, values)
+		}
+	}
+	return
+}
+
+func setFormStruct(v interface{}, values url.Values) (err error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return MustPassPtrOfStructError(reflect.TypeOf(v))
+	}
+
+	elem := value.Elem()
+	for key, index := range fieldKeys(elem.Type()) {
+		if texts, ok := values[key]; ok {
+			if err = setTexts(elem.Field(index), texts); err != nil {
+				break
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,155 @@
+package unmarshalers
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIMEMultipartMixed = "multipart/mixed"
+	HeaderContentID    = "Content-ID"
+)
+
+var (
+	MultipartChecker = WhenMediaType(headers.MIMEMultipartForm, MIMEMultipartMixed)
+
+	fileInfoType = reflect.TypeOf(FileInfo{})
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+type (
+	// MultipartCtr decodes multipart body into a struct, each part into a field;
+	// fields are keyed like UnmarshalForm, and a part is named by its form name,
+	// Content-ID or index ("0", "1", ...) in order
+	MultipartCtr struct {
+		fileCtr      *FileCtr
+		unmarshalers gotten.ConditionalUnmarshalers
+	}
+)
+
+// parts of field FileInfo or *FileInfo are streamed through fileCtr
+func NewMultipartCtr(fileCtr *FileCtr) *MultipartCtr {
+	return &MultipartCtr{
+		fileCtr:      fileCtr,
+		unmarshalers: make(gotten.ConditionalUnmarshalers, 0),
+	}
+}
+
+// parts of field struct, map, slice (except []byte and []string) or ptr are unmarshaled
+// by the first unmarshaler whose checker passes the part header;
+// gotten.DefaultUnmarshalers are checked after the added ones
+func (ctr *MultipartCtr) AddPartUnmarshaler(unmarshaler gotten.ReadUnmarshaler, checker gotten.Checker) *MultipartCtr {
+	ctr.unmarshalers = append(ctr.unmarshalers, gotten.NewConditionalUnmarshaler(unmarshaler, checker))
+	return ctr
+}
+
+func (ctr MultipartCtr) Unmarshal(reader io.ReadCloser, header http.Header, v interface{}) (err error) {
+	defer reader.Close()
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return MustPassPtrOfStructError(reflect.TypeOf(v))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get(headers.HeaderContentType))
+	if err == nil && (!strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == ZeroStr) {
+		err = NotMultipartError(mediaType)
+	}
+
+	if err == nil {
+		elem := value.Elem()
+		keys := fieldKeys(elem.Type())
+		partReader := multipart.NewReader(reader, params["boundary"])
+		for index := 0; ; index++ {
+			var part *multipart.Part
+			if part, err = partReader.NextPart(); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				break
+			}
+
+			if fieldIndex, ok := keys[partName(part, index)]; ok {
+				err = ctr.setPart(elem.Field(fieldIndex), part)
+			}
+			part.Close()
+			if err != nil {
+				break
+			}
+		}
+	}
+	return
+}
+
+func partName(part *multipart.Part, index int) (name string) {
+	name = part.FormName()
+	if name == ZeroStr {
+		name = strings.Trim(part.Header.Get(HeaderContentID), "<>")
+	}
+	if name == ZeroStr {
+		name = strconv.Itoa(index)
+	}
+	return
+}
+
+func (ctr MultipartCtr) setPart(field reflect.Value, part *multipart.Part) (err error) {
+	header := http.Header(part.Header)
+	body := ioutil.NopCloser(part)
+	fieldType := field.Type()
+	switch {
+	case fieldType == fileInfoType || fieldType == reflect.PtrTo(fileInfoType):
+		if ctr.fileCtr == nil {
+			return NoFileCtrForPartError(part.FormName())
+		}
+		info := new(FileInfo)
+		if err = ctr.fileCtr.Unmarshal(body, header, info); err == nil {
+			if fieldType == fileInfoType {
+				field.Set(reflect.ValueOf(info).Elem())
+			} else {
+				field.Set(reflect.ValueOf(info))
+			}
+		}
+	case fieldType == bytesType:
+		var data []byte
+		if data, err = ioutil.ReadAll(body); err == nil {
+			field.SetBytes(data)
+		}
+	case isText(field) || fieldType == reflect.TypeOf([]string(nil)):
+		var data []byte
+		if data, err = ioutil.ReadAll(body); err == nil {
+			err = setTexts(field, []string{string(data)})
+		}
+	default:
+		unmarshaler, exist := ctr.unmarshalers.Check(&http.Response{Header: header})
+		if !exist {
+			unmarshaler, exist = gotten.ConditionalUnmarshalers(gotten.DefaultUnmarshalers).Check(&http.Response{Header: header})
+		}
+		if !exist {
+			return NoUnmarshalerFoundForPartError(header.Get(headers.HeaderContentType))
+		}
+		err = unmarshaler.Unmarshal(body, header, field.Addr().Interface())
+	}
+	return
+}
+
+// can be set by setText
+func isText(field reflect.Value) bool {
+	if field.Addr().Type().Implements(textUnmarshalerType) {
+		return true
+	}
+	switch field.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package unmarshalers
+
+import (
+	"encoding"
+	"github.com/Hexilee/gotten"
+	"github.com/iancoleman/strcase"
+	"reflect"
+	"strconv"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setText parses text into field of encoding.TextUnmarshaler, string, bool, int, uint or float;
+// empty text leaves zero value
+func setText(field reflect.Value, text string) (err error) {
+	if text == ZeroStr {
+		return
+	}
+
+	if field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		var value bool
+		if value, err = strconv.ParseBool(text); err == nil {
+			field.SetBool(value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var value int64
+		if value, err = strconv.ParseInt(text, 10, field.Type().Bits()); err == nil {
+			field.SetInt(value)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var value uint64
+		if value, err = strconv.ParseUint(text, 10, field.Type().Bits()); err == nil {
+			field.SetUint(value)
+		}
+	case reflect.Float32, reflect.Float64:
+		var value float64
+		if value, err = strconv.ParseFloat(text, field.Type().Bits()); err == nil {
+			field.SetFloat(value)
+		}
+	default:
+		err = UnsupportedFieldTypeError(field.Type())
+	}
+	return
+}
+
+// setTexts sets a slice field by every text, or a single field by the first one
+func setTexts(field reflect.Value, texts []string) (err error) {
+	if field.Kind() == reflect.Slice && !field.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(field.Type(), len(texts), len(texts))
+		for i, text := range texts {
+			if err = setText(slice.Index(i), text); err != nil {
+				return
+			}
+		}
+		field.Set(slice)
+	} else if len(texts) > 0 {
+		err = setText(field, texts[0])
+	}
+	return
+}
+
+// key of field is the `key` tag or snake case of field name, like params of gotten;
+// returns map of key -> index of field
+func fieldKeys(structType reflect.Type) map[string]int {
+	keys := make(map[string]int)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != ZeroStr {
+			continue
+		}
+		key := field.Tag.Get(gotten.KeyKey)
+		if key == ZeroStr {
+			key = strcase.ToSnake(field.Name)
+		}
+		keys[key] = i
+	}
+	return keys
+}
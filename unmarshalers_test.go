@@ -0,0 +1,293 @@
+package gotten_test
+
+import (
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Hexilee/gotten"
+	"github.com/Hexilee/gotten/headers"
+	"github.com/Hexilee/gotten/unmarshalers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type (
+	EncodedPostsService struct {
+		Get func(*EncodedPostsParams) (gotten.Response, error) `path:"/posts.{format}"`
+	}
+
+	EncodedPostsParams struct {
+		Format string `type:"path"`
+	}
+)
+
+func TestUnmarshalers(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		AddReaderUnmarshaler(unmarshalers.YAML, unmarshalers.YAMLChecker).
+		AddReaderUnmarshaler(unmarshalers.Msgpack, unmarshalers.MsgpackChecker).
+		AddReaderUnmarshaler(unmarshalers.CBOR, unmarshalers.CBORChecker).
+		AddReaderUnmarshaler(unmarshalers.CSV, unmarshalers.CSVChecker).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(EncodedPostsService)
+	assert.Nil(t, creator.Impl(service))
+	for _, format := range []string{"yaml", "msgpack", "cbor", "csv"} {
+		resp, err := service.Get(&EncodedPostsParams{format})
+		assert.Nil(t, err, format)
+		var posts []*TestPost
+		assert.Nil(t, resp.Unmarshal(&posts), format)
+		assert.Equal(t, &TestPost{"Hexilee", "Start!", "Hello world!"}, posts[0], format)
+	}
+
+	resp, err := service.Get(&EncodedPostsParams{"csv"})
+	assert.Nil(t, err)
+	var rows []map[string]string
+	assert.Nil(t, resp.Unmarshal(&rows))
+	assert.Equal(t, map[string]string{"Author": "Hexilee", "Title": "Start!", "Content": "Hello world!"}, rows[0])
+
+	resp, err = service.Get(&EncodedPostsParams{"csv"})
+	assert.Nil(t, err)
+	var wrong TestPost
+	assert.Equal(t, unmarshalers.MustPassPtrOfSliceError(reflect.TypeOf(&wrong)), resp.Unmarshal(&wrong))
+}
+
+type (
+	FormResponseService struct {
+		GetForm      func(*struct{}) (gotten.Response, error) `path:"/form"`
+		GetMultipart func(*struct{}) (gotten.Response, error) `path:"/multipart"`
+	}
+
+	FormResult struct {
+		Uid      int
+		Username string
+		Tags     []string
+	}
+
+	MultipartResult struct {
+		Uid    int
+		Post   *TestPost
+		Avatar *unmarshalers.FileInfo
+	}
+)
+
+func TestFormAndMultipartUnmarshalers(t *testing.T) {
+	fileCtr, err := new(unmarshalers.FileCtrBuilder).SetBasePath(t.TempDir()).Build()
+	assert.Nil(t, err)
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		AddReaderUnmarshaler(unmarshalers.Form, unmarshalers.FormChecker).
+		AddReaderUnmarshaler(unmarshalers.NewMultipartCtr(fileCtr), unmarshalers.MultipartChecker).
+		Build()
+	assert.Nil(t, err)
+
+	service := new(FormResponseService)
+	assert.Nil(t, creator.Impl(service))
+	resp, err := service.GetForm(nil)
+	assert.Nil(t, err)
+	var form FormResult
+	assert.Nil(t, resp.Unmarshal(&form))
+	assert.Equal(t, FormResult{1, "Hexilee", []string{"go", "http"}}, form)
+
+	resp, err = service.GetMultipart(nil)
+	assert.Nil(t, err)
+	var result MultipartResult
+	assert.Nil(t, resp.Unmarshal(&result))
+	assert.Equal(t, 1, result.Uid)
+	assert.Equal(t, &TestPost{"Hexilee", "Multipart", "Success!"}, result.Post)
+	assert.Equal(t, "avatar.jpg", result.Avatar.Filename)
+
+	data, err := ioutil.ReadFile("testAssets/avatar.jpg")
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf("%x", md5.Sum(data)), result.Avatar.Hash)
+	saved, err := ioutil.ReadFile(result.Avatar.FilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, data, saved)
+}
+
+func TestFileCtr_Hashes(t *testing.T) {
+	data, err := ioutil.ReadFile("testAssets/avatar.jpg")
+	assert.Nil(t, err)
+	sha256Sum := sha256.Sum256(data)
+	md5Sum := md5.Sum(data)
+
+	dir := t.TempDir()
+	fileCtr, err := new(unmarshalers.FileCtrBuilder).
+		SetBasePath(dir).
+		SetHashes(crypto.SHA256, crypto.MD5).
+		Verify().
+		Build()
+	assert.Nil(t, err)
+
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		SetClient(mockClient).
+		AddReaderUnmarshaler(fileCtr, new(gotten.CheckerFactory).Create()).
+		Build()
+	assert.Nil(t, err)
+
+	var service struct {
+		GetFile func(*struct {
+			Header string `type:"path"`
+		}) (gotten.Response, error) `path:"/digest/{header}"`
+	}
+	assert.Nil(t, creator.Impl(&service))
+	for _, header := range []string{"md5", "digest", "repr-digest", "none"} {
+		resp, err := service.GetFile(&struct {
+			Header string `type:"path"`
+		}{header})
+		assert.Nil(t, err)
+		var info unmarshalers.FileInfo
+		assert.Nil(t, resp.Unmarshal(&info))
+		assert.Equal(t, hex.EncodeToString(sha256Sum[:]), info.Hash)
+		assert.Equal(t, hex.EncodeToString(md5Sum[:]), info.Hashes[crypto.MD5])
+		assert.FileExists(t, info.FilePath)
+	}
+
+	resp, err := service.GetFile(&struct {
+		Header string `type:"path"`
+	}{"wrong"})
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(filepath.Join(dir, "avatar.jpg")))
+	var info unmarshalers.FileInfo
+	assert.Equal(t, unmarshalers.HashMismatchError(hex.EncodeToString(md5Sum[:]), hex.EncodeToString(sha256Sum[:])), resp.Unmarshal(&info))
+	assert.NoFileExists(t, filepath.Join(dir, "avatar.jpg"))
+
+	// expected checksum
+	fileCtr, err = new(unmarshalers.FileCtrBuilder).
+		SetBasePath(dir).
+		Expect(crypto.SHA1, "0000").
+		Build()
+	assert.Nil(t, err)
+	resp, err = service.GetFile(&struct {
+		Header string `type:"path"`
+	}{"none"})
+	assert.Nil(t, err)
+	assert.Error(t, fileCtr.Unmarshal(resp.Body(), resp.Header(), &info))
+	assert.NoFileExists(t, filepath.Join(dir, "avatar.jpg"))
+}
+
+func unmarshalFile(ctr *unmarshalers.FileCtr, contentDisposition, content string) (info *unmarshalers.FileInfo, err error) {
+	info = new(unmarshalers.FileInfo)
+	header := make(http.Header)
+	header.Set(headers.HeaderContentDisposition, contentDisposition)
+	err = ctr.Unmarshal(ioutil.NopCloser(strings.NewReader(content)), header, info)
+	return
+}
+
+func TestFileCtr_Filename(t *testing.T) {
+	dir := t.TempDir()
+	fileCtr, err := new(unmarshalers.FileCtrBuilder).SetBasePath(dir).Build()
+	assert.Nil(t, err)
+
+	for disposition, filename := range map[string]string{
+		`attachment; filename="../../etc/passwd"`:                                      "passwd",
+		`attachment; filename="..\\..\\evil.txt"`:                                      "evil.txt",
+		`attachment; filename="a<b>:c?.txt"`:                                           "a_b__c_.txt",
+		`attachment; filename="fallback.txt"; filename*=UTF-8''%E4%BD%A0%E5%A5%BD.txt`: "你好.txt",
+		`attachment; filename*=GBK''%C4%E3%BA%C3.txt`:                                  "你好.txt",
+	} {
+		info, err := unmarshalFile(fileCtr, disposition, "content")
+		assert.Nil(t, err)
+		assert.Equal(t, filename, info.Filename)
+		assert.Equal(t, filepath.Join(dir, filename), info.FilePath)
+	}
+
+	_, err = unmarshalFile(fileCtr, `attachment; filename=".."`, "content")
+	assert.Equal(t, errors.New(unmarshalers.ContentDispositionOrFilenameEmpty), err)
+}
+
+func TestFileCtr_Collision(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "avatar.jpg")
+	assert.Nil(t, ioutil.WriteFile(existing, []byte("old"), 0644))
+	disposition := `attachment; filename="avatar.jpg"`
+
+	fileCtr, err := new(unmarshalers.FileCtrBuilder).SetBasePath(dir).SetCollisionPolicy(unmarshalers.Skip).Build()
+	assert.Nil(t, err)
+	info, err := unmarshalFile(fileCtr, disposition, "new")
+	assert.Nil(t, err)
+	assert.Equal(t, existing, info.FilePath)
+	data, err := ioutil.ReadFile(existing)
+	assert.Nil(t, err)
+	assert.Equal(t, "old", string(data))
+
+	fileCtr, err = new(unmarshalers.FileCtrBuilder).SetBasePath(dir).SetCollisionPolicy(unmarshalers.Fail).Build()
+	assert.Nil(t, err)
+	_, err = unmarshalFile(fileCtr, disposition, "new")
+	assert.Equal(t, unmarshalers.FileExistsError(existing), err)
+
+	fileCtr, err = new(unmarshalers.FileCtrBuilder).SetBasePath(dir).SetCollisionPolicy(unmarshalers.Suffix).Build()
+	assert.Nil(t, err)
+	for i := 1; i <= 2; i++ {
+		info, err = unmarshalFile(fileCtr, disposition, "new")
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("avatar-%d.jpg", i), info.Filename)
+		data, err = ioutil.ReadFile(info.FilePath)
+		assert.Nil(t, err)
+		assert.Equal(t, "new", string(data))
+	}
+
+	fileCtr, err = new(unmarshalers.FileCtrBuilder).SetBasePath(dir).Build()
+	assert.Nil(t, err)
+	info, err = unmarshalFile(fileCtr, disposition, "new")
+	assert.Nil(t, err)
+	data, err = ioutil.ReadFile(existing)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(data))
+}
+
+func TestFileCtr_FileSystem(t *testing.T) {
+	fs := unmarshalers.NewMemFileSystem()
+	fileCtr, err := new(unmarshalers.FileCtrBuilder).
+		SetBasePath("/files").
+		SetFileSystem(fs).
+		SetNamer(func(info unmarshalers.FileInfo, header http.Header) (string, error) {
+			return fmt.Sprintf("%d-%s", info.Size, info.Filename), nil
+		}).
+		Build()
+	assert.Nil(t, err)
+
+	info, err := unmarshalFile(fileCtr, `attachment; filename="hello.txt"`, "hello")
+	assert.Nil(t, err)
+	assert.Equal(t, "5-hello.txt", info.Filename)
+	assert.Equal(t, []string{filepath.Join("/files", "5-hello.txt")}, fs.Names())
+	data, err := fs.ReadFile(info.FilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	// parallel download into memory
+	data, err = ioutil.ReadFile("testAssets/avatar.jpg")
+	assert.Nil(t, err)
+	info, err = unmarshalers.NewDownloader(mockClient, fileCtr).SetSegments(3).Download(newDownloadRequest(t), gotten.ZeroStr)
+	assert.Nil(t, err)
+	saved, err := fs.ReadFile(info.FilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, data, saved)
+	assert.Equal(t, 2, len(fs.Names()))
+
+	// sink
+	buf := new(strings.Builder)
+	fileCtr, err = new(unmarshalers.FileCtrBuilder).
+		SetBasePath("/files").
+		SetFileSystem(unmarshalers.NewWriterSink(buf)).
+		Build()
+	assert.Nil(t, err)
+	_, err = unmarshalFile(fileCtr, `attachment; filename="hello.txt"`, "hello")
+	assert.Nil(t, err)
+	_, err = unmarshalFile(fileCtr, `attachment; filename="world.txt"`, " world")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", buf.String())
+}
@@ -0,0 +1,147 @@
+package gotten_test
+
+import (
+	"github.com/Hexilee/gotten"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type (
+	ValidatedService struct {
+		AddPost func(*ValidatedParams) (*http.Request, error) `method:"POST" path:"/post/{year}"`
+	}
+
+	ValidatedParams struct {
+		Year   int      `type:"path" min:"2000" max:"2100"`
+		Id     string   `type:"query" format:"uuid"`
+		Email  string   `type:"query" format:"email"`
+		State  string   `type:"query" enum:"draft,published" default:"draft"`
+		Token  string   `type:"header" len:"8" sensitive:"true"`
+		Author string   `type:"form" min:"2" max:"10" pattern:"^[A-Za-z]+$"`
+		Tags   []string `type:"json" max:"2"`
+	}
+)
+
+func TestValidation(t *testing.T) {
+	creator, err := gotten.NewBuilder().
+		SetBaseUrl("https://mock.io").
+		Build()
+	assert.Nil(t, err)
+
+	service := new(ValidatedService)
+	assert.Nil(t, creator.Impl(service))
+
+	_, err = service.AddPost(&ValidatedParams{
+		Year:   2018,
+		Id:     "3c6b5e2a-8f0e-4c1b-9a57-2f1d1d2c3b4a",
+		Email:  "hexilee@example.com",
+		Token:  "12345678",
+		Author: "Hexilee",
+		Tags:   []string{"go"},
+	})
+	assert.Nil(t, err)
+
+	// empty values are checked by require only
+	_, err = service.AddPost(&ValidatedParams{Year: 2018})
+	assert.Nil(t, err)
+
+	_, err = service.AddPost(&ValidatedParams{
+		Year:   1999,
+		Id:     "not-a-uuid",
+		Email:  "Hexilee <hexilee@example.com>",
+		State:  "deleted",
+		Token:  "secret",
+		Author: "H3",
+		Tags:   []string{"go", "http", "rest"},
+	})
+	assert.NotNil(t, err)
+	validationErr, ok := err.(*gotten.ValidationError)
+	assert.True(t, ok)
+
+	var rules []string
+	for _, fieldErr := range validationErr.Errors {
+		rules = append(rules, fieldErr.Field+"."+fieldErr.Rule)
+	}
+	assert.Equal(t, []string{
+		"Year.min",
+		"Id.format",
+		"Email.format",
+		"State.enum",
+		"Token.len",
+		"Author.pattern",
+		"Tags.max",
+	}, rules)
+	assert.Equal(t, gotten.RedactedValue, validationErr.Errors[4].Value)
+	assert.Equal(t, "1999", validationErr.Errors[0].Value)
+	assert.Equal(t, "2000", validationErr.Errors[0].Param)
+	assert.Contains(t, err.Error(), gotten.ValidationFailed)
+}
+
+func TestValidation_NamedString(t *testing.T) {
+	var service struct {
+		Upload func(*struct {
+			Avatar gotten.FilePath `type:"part" pattern:"\\.jpg$"`
+		}) (*http.Request, error) `method:"POST" path:"/avatar"`
+	}
+	creator, err := gotten.NewBuilder().SetBaseUrl("https://mock.io").Build()
+	assert.Nil(t, err)
+	assert.Nil(t, creator.Impl(&service))
+
+	_, err = service.Upload(&struct {
+		Avatar gotten.FilePath `type:"part" pattern:"\\.jpg$"`
+	}{"testAssets/avatar.jpg"})
+	assert.Nil(t, err)
+
+	_, err = service.Upload(&struct {
+		Avatar gotten.FilePath `type:"part" pattern:"\\.jpg$"`
+	}{"testAssets/avatar.png"})
+	validationErr, ok := err.(*gotten.ValidationError)
+	if assert.True(t, ok) {
+		assert.Equal(t, "pattern", validationErr.Errors[0].Rule)
+		assert.Equal(t, "testAssets/avatar.png", validationErr.Errors[0].Value)
+	}
+}
+
+func TestInvalidValidationRuleError(t *testing.T) {
+	creator, err := gotten.NewBuilder().SetBaseUrl("https://mock.io").Build()
+	assert.Nil(t, err)
+
+	var minService struct {
+		Get func(*struct {
+			Page int `type:"query" min:"one"`
+		}) (*http.Request, error)
+	}
+	err = creator.Impl(&minService)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.InvalidValidationRuleError("Page", gotten.KeyMin, "one").Error(), err.Error())
+
+	// struct is unsupported
+	var bodyService struct {
+		Add func(*struct {
+			Post *TestPost `type:"json" min:"1"`
+		}) (*http.Request, error)
+	}
+	err = creator.Impl(&bodyService)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.UnsupportedValidationTypeError("Post", gotten.KeyMin, reflect.TypeOf(&TestPost{})).Error(), err.Error())
+
+	var formatService struct {
+		Get func(*struct {
+			Id string `type:"query" format:"ipv4"`
+		}) (*http.Request, error)
+	}
+	err = creator.Impl(&formatService)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.InvalidValidationRuleError("Id", gotten.KeyFormat, "ipv4").Error(), err.Error())
+
+	var patternService struct {
+		Get func(*struct {
+			Ids []string `type:"json" pattern:"^a"`
+		}) (*http.Request, error) `method:"POST"`
+	}
+	err = creator.Impl(&patternService)
+	assert.NotNil(t, err)
+	assert.Equal(t, gotten.InvalidValidationRuleError("Ids", gotten.KeyPattern, "^a").Error(), err.Error())
+}
@@ -108,7 +108,7 @@ func TestFieldExportable(t *testing.T) {
 }
 
 func TestVarsParser(t *testing.T) {
-	parser, err := newVarsParser(ComplexPath)
+	parser, err := newVarsParser(ComplexPath, DefaultMarshalers())
 	assert.Nil(t, err)
 	assert.Nil(t, parser.parse(reflect.TypeOf(new(SimpleParams))))
 